@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// QuoteMeta returns a pattern in rek syntax matching the literal text s, where
+// every metacharacter is escaped.
+func QuoteMeta(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		sb.WriteString(escapeRune(r))
+	}
+	return sb.String()
+}
+
+// Pattern is a pattern built from code rather than text, so that no rune in
+// it needs escaping. Patterns are immutable and can be shared freely.
+type Pattern struct {
+	// build returns a new NFA of the pattern on every call, since NFAs are
+	// modified when they are connected
+	build func() *nfa
+}
+
+// Compile compiles the pattern. Unlike the function Compile, patterns matching
+// the empty string are accepted.
+func (p Pattern) Compile() REK {
+	return REK{constructDFA(p.build())}
+}
+
+// Lit returns a pattern matching the literal text s.
+func Lit(s string) Pattern {
+	return Pattern{func() *nfa {
+		n := emptyNFA()
+		for _, r := range s {
+			n.concatenate(charNFA([]rune{r}, []rune{r}))
+		}
+		return n
+	}}
+}
+
+// Class returns a pattern matching a single rune in the given ranges, which
+// are pairs of lower and upper bounds, like Class('a', 'z', '0', '9').
+func Class(pairs ...rune) Pattern {
+	if len(pairs)%2 != 0 {
+		panic("odd number of bounds in character class")
+	}
+	area := make([][]rune, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i] > pairs[i+1] || pairs[i] < 0 || pairs[i+1] > utf8.MaxRune {
+			panic("illegal range")
+		}
+		area = append(area, []rune{pairs[i], pairs[i+1]})
+	}
+	lower, upper := sortCharacterClass(false, area)
+	return Pattern{func() *nfa {
+		return charNFA(append([]rune(nil), lower...), append([]rune(nil), upper...))
+	}}
+}
+
+// Seq returns a pattern matching the patterns in series, which matches the
+// empty string if there is no pattern.
+func Seq(ps ...Pattern) Pattern {
+	return Pattern{func() *nfa {
+		n := emptyNFA()
+		for _, p := range ps {
+			n.concatenate(p.build())
+		}
+		return n
+	}}
+}
+
+// Alt returns a pattern matching any of the patterns, which matches nothing if
+// there is no pattern.
+func Alt(ps ...Pattern) Pattern {
+	return Pattern{func() *nfa {
+		if len(ps) == 0 {
+			return charNFA(nil, nil)
+		}
+		n := ps[0].build()
+		for _, p := range ps[1:] {
+			n.alternate(p.build())
+		}
+		return n
+	}}
+}
+
+// Star returns a pattern repeating p for zero times and more.
+func Star(p Pattern) Pattern {
+	return Pattern{func() *nfa {
+		n := p.build()
+		n.repeatZeroTimesAndMore()
+		return n
+	}}
+}
+
+// Plus returns a pattern repeating p for once and more.
+func Plus(p Pattern) Pattern {
+	return Pattern{func() *nfa {
+		n := p.build()
+		n.repeatOnceAndMore()
+		return n
+	}}
+}
+
+// Opt returns a pattern repeating p for once and less.
+func Opt(p Pattern) Pattern {
+	return Pattern{func() *nfa {
+		n := p.build()
+		n.repeatOnceAndLess()
+		return n
+	}}
+}
+
+// Repeat returns a pattern repeating p for min to max times, where max is -1
+// if there is no upper bound.
+func Repeat(p Pattern, min, max int) Pattern {
+	if min < 0 || max < -1 || max != -1 && min > max {
+		panic("invalid repeat count")
+	}
+	return Pattern{func() *nfa {
+		return buildRepeatNFA(p.build, min, max)
+	}}
+}
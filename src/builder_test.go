@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestQuoteMeta(t *testing.T) {
+	inputs := []string{"a+b", "1.5*(x|y)?", "[~a&b]\\", "tab\there\r\n", "π^-$"}
+	for _, s := range inputs {
+		q := QuoteMeta(s)
+		r := Compile(q)
+		if !r.Match(s) {
+			t.Errorf("Compile(QuoteMeta(%q) = %q) rejects the input", s, q)
+		}
+		if r.Match(s+"x") || r.Match(s[1:]) {
+			t.Errorf("Compile(QuoteMeta(%q) = %q) accepts other strings", s, q)
+		}
+	}
+
+	// every escape written by QuoteMeta can be decoded by the parser
+	for r := rune(0); r < 0x80; r++ {
+		if q := QuoteMeta(string(r)); len(q) == 2 {
+			if v, ok := decodeEscapable(rune(q[1])); !ok || v != r {
+				t.Errorf("QuoteMeta(%q) = %q cannot be decoded", r, q)
+			}
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	digit := Class('0', '9')
+	ident := Seq(Class('a', 'z', 'A', 'Z', '_', '_'), Star(Class('a', 'z', 'A', 'Z', '0', '9', '_', '_')))
+	cases := []struct {
+		p        Pattern
+		accepted []string
+		rejected []string
+	}{
+		{Lit("a+b(c)"), []string{"a+b(c)"}, []string{"aab(c)", "abc"}},
+		{Seq(Plus(digit), Opt(Seq(Lit("."), Repeat(digit, 1, 3)))), []string{"1", "12.5", "0.125"}, []string{".5", "1.", "1.1234"}},
+		{Alt(ident, Lit("::")), []string{"x", "_a1", "::"}, []string{"1a", ":"}},
+		{Repeat(Alt(Lit("ab"), Lit("c")), 2, -1), []string{"abc", "ccab", "abababab"}, []string{"ab", "c", ""}},
+		{Seq(Lit("x"), Repeat(Lit("y"), 0, 0), Star(Lit(""))), []string{"x"}, []string{"xy", ""}},
+		{Opt(Lit("a")), []string{"", "a"}, []string{"aa"}},
+		{Alt(), nil, []string{"", "a"}},
+		{Seq(), []string{""}, []string{"a"}},
+	}
+	for i, c := range cases {
+		// compile twice to check that patterns are reusable
+		for j := 0; j < 2; j++ {
+			r := c.p.Compile()
+			for _, s := range c.accepted {
+				if !r.Match(s) {
+					t.Errorf("pattern %d rejects %q", i, s)
+				}
+			}
+			for _, s := range c.rejected {
+				if r.Match(s) {
+					t.Errorf("pattern %d accepts %q", i, s)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+// Cache caches compiled patterns, evicting the least recently used ones when
+// it's full. It's safe for concurrent use, and concurrent compiles of the same
+// pattern are done only once.
+type Cache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, from the most recently used
+	entries map[cacheKey]*list.Element
+	calls   map[cacheKey]*cacheCall // compiles in progress
+	stats   CacheStats
+}
+
+// CacheStats are statistics of a Cache.
+type CacheStats struct {
+	// Hits is the number of compiles served from the cache, including the
+	// ones waiting for the same pattern being compiled by another goroutine.
+	Hits int64
+	// Misses is the number of patterns actually compiled.
+	Misses int64
+	// Evictions is the number of patterns evicted.
+	Evictions int64
+	// Entries and Bytes are the number of patterns in the cache and their
+	// estimated memory usage.
+	Entries int
+	Bytes   int64
+}
+
+// cacheKey identifies a compiled pattern.
+type cacheKey struct {
+	pattern string
+	opts    CompileOptions
+}
+
+// cacheEntry is a compiled pattern in the cache.
+type cacheEntry struct {
+	key  cacheKey
+	re   REK
+	size int64
+}
+
+// cacheCall is a compile in progress, which is finished when done is closed.
+type cacheCall struct {
+	done chan struct{}
+	re   REK
+	err  error
+}
+
+// NewCache returns a cache holding at most maxEntries patterns, whose memory
+// usage is at most maxBytes. A limit of 0 means no limit.
+func NewCache(maxEntries int, maxBytes int64) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		entries:    map[cacheKey]*list.Element{},
+		calls:      map[cacheKey]*cacheCall{},
+	}
+}
+
+// Compile returns the compiled pattern like CompileWithOptions, from the cache
+// if possible. Errors are not cached.
+func (c *Cache) Compile(pattern string, opts CompileOptions) (REK, error) {
+	key := cacheKey{pattern, opts}
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).re, nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.stats.Hits++
+		c.mu.Unlock()
+		<-call.done
+		return call.re, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.err == nil {
+			c.add(&cacheEntry{key, call.re, call.re.d.memorySize()})
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	// waiters get an error rather than an invalid REK if compiling panics
+	call.err = errors.New("compiling panicked")
+	call.re, call.err = CompileWithOptions(pattern, opts)
+	return call.re, call.err
+}
+
+// add adds an entry to the cache and evicts entries if necessary, where c.mu
+// is held. Entries larger than the memory limit are not added.
+func (c *Cache) add(e *cacheEntry) {
+	if c.maxBytes > 0 && e.size > c.maxBytes {
+		return
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.stats.Entries++
+	c.stats.Bytes += e.size
+	for c.maxEntries > 0 && c.stats.Entries > c.maxEntries || c.maxBytes > 0 && c.stats.Bytes > c.maxBytes {
+		last := c.lru.Back()
+		old := last.Value.(*cacheEntry)
+		c.lru.Remove(last)
+		delete(c.entries, old.key)
+		c.stats.Entries--
+		c.stats.Bytes -= old.size
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// memorySize estimates the memory used by the DFA in bytes, including the
+// automata and literals used by searching, which are built first if they are
+// not built yet, so that the size doesn't grow after it's estimated.
+func (d *dfa) memorySize() int64 {
+	rev, info := d.reverseDFA(), d.literals()
+	size := d.automatonSize() + rev.automatonSize() + info.trimmed.automatonSize()
+	size += int64(len(d.required) + len(info.firsts))
+	for _, p := range info.prefixes {
+		size += int64(unsafe.Sizeof(p)) + int64(len(p))
+	}
+	return size
+}
+
+// automatonSize estimates the memory used by the states of the DFA in bytes.
+func (d *dfa) automatonSize() int64 {
+	size := int64(unsafe.Sizeof(*d)) + int64(cap(d.fail))*int64(unsafe.Sizeof(0))
+	for i, s := range d.states {
+		size += int64(unsafe.Sizeof(s)) + int64(cap(s.transfers))*int64(unsafe.Sizeof(dfaTransfer{}))
+		if d.nfaStates != nil {
+			size += int64(unsafe.Sizeof(d.nfaStates[i])) + int64(cap(d.nfaStates[i]))*int64(unsafe.Sizeof(0))
+		}
+	}
+	return size
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(2, 0)
+	for _, p := range []string{"a+", "b+", "a+", "c+", "b+"} {
+		r, err := c.Compile(p, CompileOptions{})
+		if err != nil || !r.Match(p[:1]) {
+			t.Fatalf("Compile(%q) = %v", p, err)
+		}
+	}
+	// b+ is evicted by c+, as a+ is used more recently
+	s := c.Stats()
+	if s.Hits != 1 || s.Misses != 4 || s.Evictions != 2 || s.Entries != 2 || s.Bytes <= 0 {
+		t.Errorf("Stats() = %+v", s)
+	}
+
+	// options are part of the key, and errors are not cached
+	if _, err := c.Compile("a\\{2\\}", CompileOptions{Syntax: SyntaxBRE}); err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Compile("a\\{2\\}", CompileOptions{}); err == nil {
+			t.Error("Compile succeeded")
+		}
+	}
+	if s := c.Stats(); s.Misses != 7 || s.Entries != 2 {
+		t.Errorf("Stats() = %+v", s)
+	}
+
+	// patterns larger than the memory limit are not cached
+	r, _ := c.Compile("a+", CompileOptions{})
+	size := r.d.memorySize()
+	c = NewCache(0, size)
+	c.Compile("a+", CompileOptions{})
+	c.Compile("[a-z]+[0-9]+", CompileOptions{})
+	if s := c.Stats(); s.Entries != 1 || s.Bytes != size {
+		t.Errorf("Stats() = %+v", s)
+	}
+
+	// searching doesn't make cached patterns larger than estimated, even if
+	// the reverse DFA is far larger than the forward one
+	c = NewCache(0, 0)
+	r, _ = c.Compile(strings.Repeat("[ab]", 10)+"a", CompileOptions{})
+	r.Contains("xxabababababax")
+	r.FindAllString("abababababab", -1)
+	if s := c.Stats(); s.Bytes != r.d.memorySize() || s.Bytes < r.d.reverse.automatonSize() {
+		t.Errorf("Stats() = %+v, want %d bytes", s, r.d.memorySize())
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(0, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := []string{"(a|b)*c[0-9]+", "x+y+z+"}[i%2]
+			r, err := c.Compile(p, CompileOptions{})
+			if err != nil || r.Match("abc") {
+				t.Errorf("Compile(%q) = %v", p, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s := c.Stats(); s.Misses != 2 || s.Hits != 48 {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
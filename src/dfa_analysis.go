@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math/big"
+	"unicode/utf8"
+)
+
+// Equivalent reports whether a and b match exactly the same strings. If not,
+// it also returns the shortest string matched by only one of them.
+func Equivalent(a, b *REK) (bool, string) {
+	return distinguish(a.d, b.d, func(x, y bool) bool { return x != y })
+}
+
+// Subset reports whether every string matched by a is also matched by b. If
+// not, it also returns the shortest string matched by a but not by b.
+func Subset(a, b *REK) (bool, string) {
+	return distinguish(a.d, b.d, func(x, y bool) bool { return x && !y })
+}
+
+// ShortestMatch returns the shortest string matched by re. Among strings of
+// the same length, the one with the smallest runes is chosen. It returns false
+// if re matches nothing.
+func (re *REK) ShortestMatch() (string, bool) {
+	// a DFA differs from itself only by the end flags of its states
+	ok, s := distinguish(re.d, re.d, func(x, _ bool) bool { return x })
+	return s, !ok
+}
+
+// IsEmpty reports whether re matches no string at all.
+func (re *REK) IsEmpty() bool {
+	_, ok := re.ShortestMatch()
+	return !ok
+}
+
+// IsFinite reports whether re matches a finite number of strings.
+func (re *REK) IsFinite() bool {
+	useful := re.d.usefulStates()
+	// search for a cycle among useful states reachable from the start state
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	color := make([]int, len(re.d.states))
+	var hasCycle func(state int) bool
+	hasCycle = func(state int) bool {
+		color[state] = visiting
+		for _, t := range re.d.states[state].transfers {
+			if !useful[t.target] || runeCount(t.lower, t.upper) == 0 {
+				continue
+			}
+			if color[t.target] == visiting ||
+				color[t.target] == unvisited && hasCycle(t.target) {
+				return true
+			}
+		}
+		color[state] = visited
+		return false
+	}
+	return !useful[0] || !hasCycle(0)
+}
+
+// Enumerate calls fn with every string matched by re that is no longer than
+// maxLen runes, shorter strings first and strings of the same length in
+// lexicographic order. Enumeration stops when fn returns false.
+func (re *REK) Enumerate(maxLen int, fn func(string) bool) {
+	if maxLen < 0 {
+		return
+	}
+	// canEnd[k][i] reports whether an end state is reachable from state i by
+	// consuming exactly k runes
+	canEnd := make([][]bool, maxLen+1)
+	for k := range canEnd {
+		canEnd[k] = make([]bool, len(re.d.states))
+		for i, s := range re.d.states {
+			if k == 0 {
+				canEnd[k][i] = s.isEnd
+				continue
+			}
+			for _, t := range s.transfers {
+				if canEnd[k-1][t.target] && runeCount(t.lower, t.upper) > 0 {
+					canEnd[k][i] = true
+					break
+				}
+			}
+		}
+	}
+
+	input := make([]rune, 0, maxLen)
+	var walk func(state, rest int) bool
+	walk = func(state, rest int) bool {
+		if rest == 0 {
+			return fn(string(input))
+		}
+		for _, t := range re.d.states[state].transfers {
+			if !canEnd[rest-1][t.target] {
+				continue
+			}
+			for r := t.lower; r <= t.upper; r++ {
+				if !utf8.ValidRune(r) {
+					continue
+				}
+				input = append(input, r)
+				ok := walk(t.target, rest-1)
+				input = input[:len(input)-1]
+				if !ok {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	for length := 0; length <= maxLen; length++ {
+		if canEnd[length][0] && !walk(0, length) {
+			return
+		}
+	}
+}
+
+// Count returns the number of strings of exactly length runes matched by re.
+func (re *REK) Count(length int) *big.Int {
+	if length < 0 {
+		return big.NewInt(0)
+	}
+	return re.d.countTable(length)[length][0]
+}
+
+// countTable returns a table whose element [k][i] is the number of strings of
+// exactly k runes leading state i to an end state, for all k <= length.
+func (d *dfa) countTable(length int) [][]*big.Int {
+	count := make([][]*big.Int, length+1)
+	for k := range count {
+		count[k] = make([]*big.Int, len(d.states))
+		for i, s := range d.states {
+			count[k][i] = big.NewInt(0)
+			if k == 0 {
+				if s.isEnd {
+					count[k][i].SetInt64(1)
+				}
+				continue
+			}
+			for _, t := range s.transfers {
+				x := big.NewInt(runeCount(t.lower, t.upper))
+				count[k][i].Add(count[k][i], x.Mul(x, count[k-1][t.target]))
+			}
+		}
+	}
+	return count
+}
+
+// distinguish walks the product of two DFAs in breadth-first order, looking
+// for a pair of states whose end flags differ. It returns false and the
+// shortest input leading to such a pair if one exists.
+func distinguish(a, b *dfa, differ func(x, y bool) bool) (bool, string) {
+	type node struct {
+		x, y, prev int
+		input      rune
+	}
+	type pair struct{ x, y int }
+	queue := []node{{0, 0, -1, 0}}
+	isVisited := map[pair]bool{{0, 0}: true}
+	for i := 0; i < len(queue); i++ {
+		p := queue[i]
+		if differ(a.isEndOf(p.x), b.isEndOf(p.y)) {
+			var input []rune
+			for j := i; queue[j].prev != -1; j = queue[j].prev {
+				input = append(input, queue[j].input)
+			}
+			for l, r := 0, len(input)-1; l < r; l, r = l+1, r-1 {
+				input[l], input[r] = input[r], input[l]
+			}
+			return false, string(input)
+		}
+		forEachSegment(a.transfersOf(p.x), b.transfersOf(p.y), func(lower, upper rune, x, y int) {
+			r, ok := firstValidRune(lower, upper)
+			if !ok || x == -1 && y == -1 || isVisited[pair{x, y}] {
+				return
+			}
+			isVisited[pair{x, y}] = true
+			queue = append(queue, node{x, y, i, r})
+		})
+	}
+	return true, ""
+}
+
+// firstValidRune returns the smallest rune in [lower, upper] that can be
+// encoded in UTF-8, i.e. the smallest rune that is not a surrogate.
+func firstValidRune(lower, upper rune) (rune, bool) {
+	if utf8.ValidRune(lower) {
+		return lower, true
+	}
+	if lower <= 0xdfff && 0xe000 <= upper {
+		return 0xe000, true
+	}
+	return 0, false
+}
+
+// runeCount returns the number of runes in [lower, upper] that can be encoded
+// in UTF-8. Surrogates are excluded since no Go string can contain them.
+func runeCount(lower, upper rune) int64 {
+	n := int64(upper) - int64(lower) + 1
+	if lower <= 0xdfff && 0xd800 <= upper {
+		l, u := lower, upper
+		if l < 0xd800 {
+			l = 0xd800
+		}
+		if u > 0xdfff {
+			u = 0xdfff
+		}
+		n -= int64(u) - int64(l) + 1
+	}
+	return n
+}
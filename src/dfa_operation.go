@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Intersect returns a REK matching strings matched by both a and b.
+func Intersect(a, b *REK) REK {
+	return REK{product(a.d, b.d, func(x, y bool) bool { return x && y })}
+}
+
+// Union returns a REK matching strings matched by a or b.
+func Union(a, b *REK) REK {
+	return REK{product(a.d, b.d, func(x, y bool) bool { return x || y })}
+}
+
+// Difference returns a REK matching strings matched by a but not by b.
+func Difference(a, b *REK) REK {
+	return REK{product(a.d, b.d, func(x, y bool) bool { return x && !y })}
+}
+
+// Complement returns a REK matching strings not matched by a.
+func Complement(a *REK) REK {
+	// pairing a DFA with itself simply completes it with a dead state
+	return REK{product(a.d, a.d, func(x, _ bool) bool { return !x })}
+}
+
+// transfersOf returns transfers of a DFA state, where -1 is the dead state.
+func (d *dfa) transfersOf(state int) []dfaTransfer {
+	if state == -1 {
+		return nil
+	}
+	return d.states[state].transfers
+}
+
+// isEndOf reports whether a DFA state is an end state, where -1 is the dead state.
+func (d *dfa) isEndOf(state int) bool {
+	return state != -1 && d.states[state].isEnd
+}
+
+// forEachSegment splits the whole alphabet into maximal segments on which both
+// transfer lists are constant, and calls fn with the targets of each segment.
+// A missing transfer is reported as target -1.
+func forEachSegment(t1, t2 []dfaTransfer, fn func(lower, upper rune, x, y int)) {
+	i, j := 0, 0
+	for lower := rune(0); lower <= utf8.MaxRune; {
+		x, y, upper := -1, -1, rune(utf8.MaxRune)
+		if i < len(t1) {
+			if t1[i].lower <= lower {
+				x, upper = t1[i].target, t1[i].upper
+			} else {
+				upper = t1[i].lower - 1
+			}
+		}
+		if j < len(t2) {
+			if t2[j].lower <= lower {
+				y = t2[j].target
+				if t2[j].upper < upper {
+					upper = t2[j].upper
+				}
+			} else if t2[j].lower-1 < upper {
+				upper = t2[j].lower - 1
+			}
+		}
+		fn(lower, upper, x, y)
+		if i < len(t1) && t1[i].upper == upper {
+			i++
+		}
+		if j < len(t2) && t2[j].upper == upper {
+			j++
+		}
+		lower = upper + 1
+	}
+}
+
+// product builds the product automaton of two DFAs. A state of the product is
+// an end state if accept returns true for the end flags of its two components.
+// Missing transitions are completed with an explicit dead state.
+func product(a, b *dfa, accept func(x, y bool) bool) *dfa {
+	return noLimits.product(a, b, accept)
+}
+
+// product is like the function product, but aborts if the product has too
+// many states or uses too much memory, or if the context is done.
+func (l *compileLimits) product(a, b *dfa, accept func(x, y bool) bool) *dfa {
+	type pair struct{ x, y int }
+	d := &dfa{}
+	id := map[pair]int{}
+	var pairs []pair
+	var memory int64
+	addMemory := func(bytes int64) {
+		memory += bytes
+		l.checkMemory(memory)
+	}
+	addPair := func(p pair) int {
+		if i, ok := id[p]; ok {
+			return i
+		}
+		id[p] = len(pairs)
+		pairs = append(pairs, p)
+		d.states = append(d.states, dfaState{accept(a.isEndOf(p.x), b.isEndOf(p.y)), nil})
+		l.checkDFAStates(len(d.states))
+		// the pair is stored in both pairs and id
+		addMemory(int64(unsafe.Sizeof(dfaState{})) + 3*int64(unsafe.Sizeof(p)))
+		return len(pairs) - 1
+	}
+
+	addPair(pair{0, 0})
+	for i := 0; i < len(pairs); i++ {
+		l.check()
+		p := pairs[i]
+		forEachSegment(a.transfersOf(p.x), b.transfersOf(p.y), func(lower, upper rune, x, y int) {
+			next := addPair(pair{x, y})
+			d.states[i].transfers = append(d.states[i].transfers, dfaTransfer{next, lower, upper})
+			addMemory(int64(unsafe.Sizeof(dfaTransfer{})))
+		})
+	}
+	return trimDFA(d)
+}
+
+// trimDFA removes states from which no end state is reachable (except the
+// start state), and merges adjacent transfers leading to the same state.
+func trimDFA(d *dfa) *dfa {
+	useful := d.usefulStates()
+	useful[0] = true
+
+	// renumber useful states while keeping their order
+	index := make([]int, len(d.states))
+	var count int
+	for i := range d.states {
+		if useful[i] {
+			index[i] = count
+			count++
+		} else {
+			index[i] = -1
+		}
+	}
+	result := &dfa{states: make([]dfaState, 0, count)}
+	for i, s := range d.states {
+		if !useful[i] {
+			continue
+		}
+		var transfers []dfaTransfer
+		for _, t := range s.transfers {
+			next := index[t.target]
+			if next == -1 {
+				continue
+			}
+			if last := len(transfers) - 1; last >= 0 &&
+				transfers[last].target == next && transfers[last].upper+1 == t.lower {
+				transfers[last].upper = t.upper
+			} else {
+				transfers = append(transfers, dfaTransfer{next, t.lower, t.upper})
+			}
+		}
+		result.states = append(result.states, dfaState{s.isEnd, transfers})
+	}
+	return result
+}
+
+// usefulStates reports for every state whether an end state is reachable from
+// it, by walking transfers backwards from end states.
+func (d *dfa) usefulStates() []bool {
+	from := make([][]int, len(d.states))
+	var queue []int
+	useful := make([]bool, len(d.states))
+	for i, s := range d.states {
+		for _, t := range s.transfers {
+			from[t.target] = append(from[t.target], i)
+		}
+		if s.isEnd {
+			useful[i] = true
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, q := range from[p] {
+			if !useful[q] {
+				useful[q] = true
+				queue = append(queue, q)
+			}
+		}
+	}
+	return useful
+}
+
+// complementNFA returns an NFA accepting strings not accepted by n.
+func (l *compileLimits) complementNFA(n *nfa) *nfa {
+	d := l.constructDFA(n)
+	return convertDFAToNFA(l.product(d, d, func(x, _ bool) bool { return !x }))
+}
+
+// intersectNFA returns an NFA accepting strings accepted by both a and b.
+func (l *compileLimits) intersectNFA(a, b *nfa) *nfa {
+	d := l.product(l.constructDFA(a), l.constructDFA(b), func(x, y bool) bool { return x && y })
+	return convertDFAToNFA(d)
+}
+
+// convertDFAToNFA converts DFA to NFA, so that it can be connected with other
+// NFAs. Every end state of the DFA gets an empty transfer to a new end state.
+func convertDFAToNFA(d *dfa) *nfa {
+	n := &nfa{states: make([]*nfaState, len(d.states)+1)}
+	for i := range n.states {
+		n.states[i] = &nfaState{}
+	}
+	end := n.endState()
+	for i, s := range d.states {
+		// transfers to the same state share a single NFA transfer
+		dict := map[int]*nfaTransfer{}
+		for _, t := range s.transfers {
+			if x, ok := dict[t.target]; ok {
+				x.lower = append(x.lower, t.lower)
+				x.upper = append(x.upper, t.upper)
+				continue
+			}
+			x := &nfaTransfer{n.states[t.target], false, []rune{t.lower}, []rune{t.upper}}
+			dict[t.target] = x
+			n.states[i].transfers = append(n.states[i].transfers, x)
+			if t.target == 0 {
+				n.toStart = append(n.toStart, x)
+			}
+		}
+		if s.isEnd {
+			n.states[i].transfers = append(n.states[i].transfers, &nfaTransfer{end, true, nil, nil})
+			n.toEnd = append(n.toEnd, n.states[i].peek())
+		}
+	}
+	return n
+}
+
+// minimizeDFA returns the minimal DFA accepting the same strings as d. States
+// are numbered in the order they first appear in d, so the start state is
+// still the first state.
+func minimizeDFA(d *dfa) *dfa {
+	d = trimDFA(d)
+
+	// refine partition of states until it's stable, starting from end states
+	// and other states
+	class := make([]int, len(d.states))
+	for i, s := range d.states {
+		if s.isEnd {
+			class[i] = 1
+		}
+	}
+	count := -1
+	for {
+		dict := map[string]int{}
+		next := make([]int, len(d.states))
+		for i, s := range d.states {
+			key := fmt.Sprint(class[i], mergeTransfers(s.transfers, class))
+			if _, ok := dict[key]; !ok {
+				dict[key] = len(dict)
+			}
+			next[i] = dict[key]
+		}
+		class = next
+		if len(dict) == count {
+			break
+		}
+		count = len(dict)
+	}
+
+	// build DFA from classes
+	result := &dfa{states: make([]dfaState, count)}
+	isBuilt := make([]bool, count)
+	for i, s := range d.states {
+		if !isBuilt[class[i]] {
+			result.states[class[i]] = dfaState{s.isEnd, mergeTransfers(s.transfers, class)}
+			isBuilt[class[i]] = true
+		}
+	}
+	return result
+}
+
+// mergeTransfers replaces target of each transfer with its class, and merges
+// adjacent transfers leading to the same class.
+func mergeTransfers(transfers []dfaTransfer, class []int) []dfaTransfer {
+	var result []dfaTransfer
+	for _, t := range transfers {
+		next := class[t.target]
+		if last := len(result) - 1; last >= 0 &&
+			result[last].target == next && result[last].upper+1 == t.lower {
+			result[last].upper = t.upper
+		} else {
+			result = append(result, dfaTransfer{next, t.lower, t.upper})
+		}
+	}
+	return result
+}
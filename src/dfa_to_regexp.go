@@ -0,0 +1,463 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// String returns a pattern in rek syntax matching the same strings as re.
+func (re *REK) String() string {
+	return re.ToPattern()
+}
+
+// ToPattern converts the minimized DFA of re back into a pattern in rek syntax
+// by state elimination. Note that the pattern can only be compiled by Compile
+// if re does not match the empty string.
+func (re *REK) ToPattern() string {
+	t := eliminateStates(minimizeDFA(re.d))
+	if t == nil {
+		// nothing is matched
+		return "~(.|\\n)*"
+	}
+	if t.kind == termEmpty {
+		// only the empty string is matched
+		return "~(.|\\n)+"
+	}
+	return t.String()
+}
+
+// Simplify returns a pattern equivalent to re, which is converted back from
+// the minimized DFA of re if that is shorter than re itself.
+func Simplify(re string) string {
+	r := Compile(re)
+	if s := r.ToPattern(); utf8.RuneCountInString(s) < utf8.RuneCountInString(re) {
+		return s
+	}
+	return re
+}
+
+// termKind is the kind of a term.
+type termKind int
+
+const (
+	termEmpty termKind = iota
+	termClass
+	termConcat
+	termAlternate
+	termStar
+)
+
+// term is a regular expression built during state elimination. A nil term
+// matches nothing, while a term of kind termEmpty matches the empty string.
+type term struct {
+	kind         termKind
+	lower, upper []rune
+	subs         []*term
+}
+
+// equal reports whether two terms are written in the same way.
+func (t *term) equal(s *term) bool {
+	return t.String() == s.String()
+}
+
+// concatTerms connects two terms in series.
+func concatTerms(a, b *term) *term {
+	if a == nil || b == nil {
+		return nil
+	}
+	if a.kind == termEmpty {
+		return b
+	}
+	if b.kind == termEmpty {
+		return a
+	}
+	var subs []*term
+	for _, t := range []*term{a, b} {
+		if t.kind == termConcat {
+			subs = append(subs, t.subs...)
+		} else {
+			subs = append(subs, t)
+		}
+	}
+	return &term{kind: termConcat, subs: subs}
+}
+
+// alternateTerms connects two terms in parallel. Character classes are merged
+// into a single one, and common prefixes and suffixes are factored out.
+func alternateTerms(a, b *term) *term {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	// flatten alternatives
+	var subs []*term
+	for _, t := range []*term{a, b} {
+		if t.kind == termAlternate {
+			subs = append(subs, t.subs...)
+		} else {
+			subs = append(subs, t)
+		}
+	}
+
+	// merge classes and remove duplicates
+	var class *term
+	var hasEmpty, hasStar bool
+	var rest []*term
+	for _, t := range subs {
+		switch t.kind {
+		case termClass:
+			if class == nil {
+				class = t
+			} else {
+				class = mergeClassTerms(class, t)
+			}
+			continue
+		case termEmpty:
+			hasEmpty = true
+			continue
+		case termStar:
+			hasStar = true
+		}
+		isDuplicate := false
+		for _, r := range rest {
+			isDuplicate = isDuplicate || r.equal(t)
+		}
+		if !isDuplicate {
+			rest = append(rest, t)
+		}
+	}
+	if class != nil {
+		rest = append([]*term{class}, rest...)
+	}
+
+	// factor out common prefixes and suffixes
+	for i := 0; i < len(rest); i++ {
+		for j := i + 1; j < len(rest); j++ {
+			if t := factorTerms(rest[i], rest[j]); t != nil {
+				rest[i] = t
+				rest = append(rest[:j], rest[j+1:]...)
+				j = i
+			}
+		}
+	}
+
+	// a star already matches the empty string
+	if hasEmpty && (!hasStar || len(rest) > 1) {
+		rest = append(rest, &term{kind: termEmpty})
+	}
+	if len(rest) == 1 {
+		return rest[0]
+	}
+	return &term{kind: termAlternate, subs: rest}
+}
+
+// factorTerms returns a single term equivalent to a|b if a and b have a common
+// prefix or suffix, and nil otherwise.
+func factorTerms(a, b *term) *term {
+	split := func(t *term) []*term {
+		if t.kind == termConcat {
+			return t.subs
+		}
+		return []*term{t}
+	}
+	join := func(subs []*term) *term {
+		if len(subs) == 0 {
+			return &term{kind: termEmpty}
+		}
+		if len(subs) == 1 {
+			return subs[0]
+		}
+		return &term{kind: termConcat, subs: subs}
+	}
+
+	x, y := split(a), split(b)
+	var prefix, suffix int
+	for prefix < len(x) && prefix < len(y) && x[prefix].equal(y[prefix]) {
+		prefix++
+	}
+	for suffix < len(x)-prefix && suffix < len(y)-prefix &&
+		x[len(x)-1-suffix].equal(y[len(y)-1-suffix]) {
+		suffix++
+	}
+	if prefix == 0 && suffix == 0 {
+		return nil
+	}
+	middle := alternateTerms(join(x[prefix:len(x)-suffix]), join(y[prefix:len(y)-suffix]))
+	t := concatTerms(join(x[:prefix]), middle)
+	return concatTerms(t, join(x[len(x)-suffix:]))
+}
+
+// mergeClassTerms merges two character classes.
+func mergeClassTerms(a, b *term) *term {
+	var area [][]rune
+	for _, t := range []*term{a, b} {
+		for i := range t.lower {
+			area = append(area, []rune{t.lower[i], t.upper[i]})
+		}
+	}
+	lower, upper := sortCharacterClass(false, area)
+	return &term{kind: termClass, lower: lower, upper: upper}
+}
+
+// starTerm repeats a term for zero times and more.
+func starTerm(t *term) *term {
+	if t == nil || t.kind == termEmpty {
+		return &term{kind: termEmpty}
+	}
+	if t.kind == termStar {
+		return t
+	}
+	if t.kind == termAlternate {
+		// the empty string is useless in a star
+		var subs []*term
+		for _, s := range t.subs {
+			if s.kind != termEmpty {
+				subs = append(subs, s)
+			}
+		}
+		if len(subs) == 1 {
+			return starTerm(subs[0])
+		}
+		t = &term{kind: termAlternate, subs: subs}
+	}
+	return &term{kind: termStar, subs: []*term{t}}
+}
+
+// eliminateStates converts DFA to a term by state elimination.
+func eliminateStates(d *dfa) *term {
+	// edges of the generalized NFA, where the last two states are the new
+	// start and end states
+	size := len(d.states) + 2
+	start, end := size-2, size-1
+	edge := make([][]*term, size)
+	for i := range edge {
+		edge[i] = make([]*term, size)
+	}
+	for i, s := range d.states {
+		for _, t := range s.transfers {
+			class := &term{kind: termClass, lower: []rune{t.lower}, upper: []rune{t.upper}}
+			edge[i][t.target] = alternateTerms(edge[i][t.target], class)
+		}
+		if s.isEnd {
+			edge[i][end] = &term{kind: termEmpty}
+		}
+	}
+	edge[start][0] = &term{kind: termEmpty}
+
+	// eliminate states with the fewest paths through them first
+	isEliminated := make([]bool, size)
+	for n := 0; n < len(d.states); n++ {
+		k, best := -1, 0
+		for i := 0; i < len(d.states); i++ {
+			if isEliminated[i] {
+				continue
+			}
+			var in, out int
+			for j := 0; j < size; j++ {
+				if j != i && !isEliminated[j] && edge[j][i] != nil {
+					in++
+				}
+				if j != i && !isEliminated[j] && edge[i][j] != nil {
+					out++
+				}
+			}
+			if k == -1 || in*out < best {
+				k, best = i, in*out
+			}
+		}
+
+		loop := starTerm(edge[k][k])
+		for i := 0; i < size; i++ {
+			if isEliminated[i] || i == k || edge[i][k] == nil {
+				continue
+			}
+			for j := 0; j < size; j++ {
+				if isEliminated[j] || j == k || edge[k][j] == nil {
+					continue
+				}
+				path := concatTerms(concatTerms(edge[i][k], loop), edge[k][j])
+				edge[i][j] = alternateTerms(edge[i][j], path)
+			}
+		}
+		isEliminated[k] = true
+	}
+	return edge[start][end]
+}
+
+// String returns the term in rek syntax.
+func (t *term) String() string {
+	var sb strings.Builder
+	t.write(&sb)
+	return sb.String()
+}
+
+// write writes the term in rek syntax.
+func (t *term) write(sb *strings.Builder) {
+	switch t.kind {
+	case termClass:
+		sb.WriteString(classString(t.lower, t.upper))
+	case termConcat:
+		for i := 0; i < len(t.subs); i++ {
+			s := t.subs[i]
+			// x followed by x* is x+
+			if i+1 < len(t.subs) && t.subs[i+1].kind == termStar && t.subs[i+1].subs[0].equal(s) {
+				writeRepeat(sb, s, '+')
+				i++
+			} else if s.kind == termAlternate && !s.isOptional() {
+				sb.WriteByte('(')
+				s.write(sb)
+				sb.WriteByte(')')
+			} else {
+				s.write(sb)
+			}
+		}
+	case termAlternate:
+		if t.isOptional() {
+			var subs []*term
+			for _, s := range t.subs {
+				if s.kind != termEmpty {
+					subs = append(subs, s)
+				}
+			}
+			s := subs[0]
+			if len(subs) > 1 {
+				s = &term{kind: termAlternate, subs: subs}
+			}
+			writeRepeat(sb, s, '?')
+			return
+		}
+		for i, s := range t.subs {
+			if i > 0 {
+				sb.WriteByte('|')
+			}
+			s.write(sb)
+		}
+	case termStar:
+		writeRepeat(sb, t.subs[0], '*')
+	}
+}
+
+// isOptional reports whether the term is an alternation with the empty string.
+func (t *term) isOptional() bool {
+	if t.kind != termAlternate {
+		return false
+	}
+	for _, s := range t.subs {
+		if s.kind == termEmpty {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRepeat writes a repeated term.
+func writeRepeat(sb *strings.Builder, t *term, op byte) {
+	if t.kind == termClass {
+		sb.WriteString(classString(t.lower, t.upper))
+	} else {
+		sb.WriteByte('(')
+		t.write(sb)
+		sb.WriteByte(')')
+	}
+	sb.WriteByte(op)
+}
+
+// classString returns character class in rek syntax, which is a literal, a
+// wildcard, a character class or a negative character class.
+func classString(lower, upper []rune) string {
+	if len(lower) == 1 && lower[0] == upper[0] {
+		return escapeRune(lower[0])
+	}
+	if len(lower) == 2 && lower[0] == 0 && upper[0] == '\n'-1 &&
+		lower[1] == '\n'+1 && upper[1] == utf8.MaxRune {
+		return "."
+	}
+	if len(lower) == 1 && lower[0] == 0 && upper[0] == utf8.MaxRune {
+		return "(.|\\n)"
+	}
+
+	var pos strings.Builder
+	pos.WriteByte('[')
+	writeClassArea(&pos, lower, upper)
+	pos.WriteByte(']')
+
+	// try negative character class
+	var invLower, invUpper []rune
+	var last rune
+	for i := range lower {
+		if last < lower[i] {
+			invLower = append(invLower, last)
+			invUpper = append(invUpper, lower[i]-1)
+		}
+		last = upper[i] + 1
+	}
+	if last <= utf8.MaxRune {
+		invLower = append(invLower, last)
+		invUpper = append(invUpper, utf8.MaxRune)
+	}
+	var neg strings.Builder
+	neg.WriteString("[^")
+	writeClassArea(&neg, invLower, invUpper)
+	neg.WriteByte(']')
+	if neg.Len() < pos.Len() {
+		return neg.String()
+	}
+	return pos.String()
+}
+
+// writeClassArea writes ranges of a character class in rek syntax.
+func writeClassArea(sb *strings.Builder, lower, upper []rune) {
+	for i := range lower {
+		l, u := lower[i], upper[i]
+		// surrogates can never be matched, so avoid writing them
+		if 0xd800 <= l && l <= 0xdfff {
+			l = 0xe000
+		}
+		if 0xd800 <= u && u <= 0xdfff {
+			u = 0xd7ff
+		}
+		if l > u {
+			continue
+		}
+		sb.WriteString(escapeClassRune(l))
+		if l+1 < u {
+			sb.WriteByte('-')
+		}
+		if l < u {
+			sb.WriteString(escapeClassRune(u))
+		}
+	}
+}
+
+// escapeRune returns the rune in rek syntax outside character classes.
+func escapeRune(r rune) string {
+	switch r {
+	case '\t':
+		return "\\t"
+	case '\r':
+		return "\\r"
+	case '\n':
+		return "\\n"
+	case '\\', '(', ')', '*', '+', '?', '|', '.', '[', ']', '&', '~':
+		return "\\" + string(r)
+	}
+	return string(r)
+}
+
+// escapeClassRune returns the rune in rek syntax inside character classes.
+func escapeClassRune(r rune) string {
+	switch r {
+	case '\t':
+		return "\\t"
+	case '\r':
+		return "\\r"
+	case '\n':
+		return "\\n"
+	case '\\', ']', '^', '-':
+		return "\\" + string(r)
+	}
+	return string(r)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MatchReport describes where and why a string is rejected by a REK.
+type MatchReport struct {
+	// Matched reports whether the whole string is matched.
+	Matched bool
+	// Offset and RuneOffset are the byte and rune offsets where the string is
+	// rejected, which is either the offset of the first rune that can't be
+	// matched, or the length of the string if it ends too early.
+	Offset, RuneOffset int
+	// Rune is the rune at Offset, and AtEnd reports whether Offset is the end
+	// of the string instead.
+	Rune  rune
+	AtEnd bool
+	// LongestPrefix is the end of the longest matched prefix in bytes, or -1
+	// if no prefix is matched.
+	LongestPrefix int
+	// Expected is the set of runes that could be matched at Offset, as a
+	// character class in rek syntax, or the empty string if there is none.
+	Expected string
+	// EndExpected reports whether the string could end at Offset.
+	EndExpected bool
+}
+
+// String returns a message like `unexpected "x" at offset 6, expected [0-9]`.
+func (r MatchReport) String() string {
+	if r.Matched {
+		return "matched"
+	}
+	got := fmt.Sprintf("%q", r.Rune)
+	if r.AtEnd {
+		got = "end of input"
+	}
+	var expected string
+	switch {
+	case r.Expected != "" && r.EndExpected:
+		expected = r.Expected + " or end of input"
+	case r.Expected != "":
+		expected = r.Expected
+	default:
+		expected = "end of input"
+	}
+	return fmt.Sprintf("unexpected %s at offset %d, expected %s", got, r.Offset, expected)
+}
+
+// Explain matches s against re like Match, and reports where and why s is
+// rejected.
+func (re *REK) Explain(s string) MatchReport {
+	useful := re.d.usefulStates()
+	r := MatchReport{LongestPrefix: -1}
+	state := 0
+	for _, ch := range s {
+		if re.d.states[state].isEnd {
+			r.LongestPrefix = r.Offset
+		}
+		next := re.d.nextState(state, ch)
+		if next == -1 || !useful[next] {
+			r.Rune = ch
+			r.fillExpected(re.d, state, useful)
+			return r
+		}
+		state = next
+		_, size := utf8.DecodeRuneInString(s[r.Offset:])
+		r.Offset += size
+		r.RuneOffset++
+	}
+	r.AtEnd = true
+	if re.d.states[state].isEnd {
+		r.LongestPrefix = r.Offset
+		r.Matched = true
+		return r
+	}
+	r.fillExpected(re.d, state, useful)
+	return r
+}
+
+// fillExpected fills the runes expected in a state of d, where transfers to
+// states that aren't useful are ignored.
+func (r *MatchReport) fillExpected(d *dfa, state int, useful []bool) {
+	var area [][]rune
+	for _, t := range d.states[state].transfers {
+		if useful[t.target] {
+			area = append(area, []rune{t.lower, t.upper})
+		}
+	}
+	if len(area) > 0 {
+		r.Expected = classString(sortCharacterClass(false, area))
+	}
+	r.EndExpected = d.states[state].isEnd
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestExplain(t *testing.T) {
+	cases := []struct {
+		re, in string
+		report MatchReport
+		msg    string
+	}{
+		{"[a-z]+=[0-9]+", "key=12",
+			MatchReport{Matched: true, Offset: 6, RuneOffset: 6, AtEnd: true, LongestPrefix: 6},
+			"matched"},
+		{"[a-z]+=[0-9]+", "clé=x1",
+			MatchReport{Offset: 2, RuneOffset: 2, Rune: 'é', LongestPrefix: -1, Expected: "[=a-z]"},
+			`unexpected 'é' at offset 2, expected [=a-z]`},
+		{"[a-zé]+=[0-9]+", "éé=x1",
+			MatchReport{Offset: 5, RuneOffset: 3, Rune: 'x', LongestPrefix: -1, Expected: "[0-9]"},
+			"unexpected 'x' at offset 5, expected [0-9]"},
+		{"[a-z]+=[0-9]+", "key=",
+			MatchReport{Offset: 4, RuneOffset: 4, AtEnd: true, LongestPrefix: -1, Expected: "[0-9]"},
+			"unexpected end of input at offset 4, expected [0-9]"},
+		{"[a-z]+=[0-9]+", "k=1;",
+			MatchReport{Offset: 3, RuneOffset: 3, Rune: ';', LongestPrefix: 3, Expected: "[0-9]", EndExpected: true},
+			"unexpected ';' at offset 3, expected [0-9] or end of input"},
+		{"ab|abc", "abcd",
+			MatchReport{Offset: 3, RuneOffset: 3, Rune: 'd', LongestPrefix: 3, EndExpected: true},
+			"unexpected 'd' at offset 3, expected end of input"},
+		// the transfer to b can never lead to a match
+		{"a(b|c)&.c", "ab",
+			MatchReport{Offset: 1, RuneOffset: 1, Rune: 'b', LongestPrefix: -1, Expected: "c"},
+			"unexpected 'b' at offset 1, expected c"},
+	}
+	for _, c := range cases {
+		r := Compile(c.re)
+		report := r.Explain(c.in)
+		if report != c.report {
+			t.Errorf("%s: Explain(%q) = %+v, want %+v", c.re, c.in, report, c.report)
+		}
+		if s := report.String(); s != c.msg {
+			t.Errorf("%s: Explain(%q).String() = %q, want %q", c.re, c.in, s, c.msg)
+		}
+	}
+}
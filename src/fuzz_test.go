@@ -0,0 +1,194 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fuzzRunes are runes used by random patterns and inputs, including the ones
+// that need escaping.
+var fuzzRunes = []rune{'a', 'b', 'c', '\n', '.', '-', '^', ']', '\\', '*', 'é'}
+
+// fuzzOptions limit the automata compiled from random patterns.
+var fuzzOptions = CompileOptions{MaxNFAStates: 1000, MaxDFAStates: 1000, MaxMemory: 16 << 20}
+
+// nodeGenerator generates random syntax trees from fuzzing data.
+type nodeGenerator struct {
+	data []byte
+}
+
+// next consumes a byte of data and returns it modulo n, or 0 if data runs out.
+func (g *nodeGenerator) next(n int) int {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return int(b) % n
+}
+
+// generate returns a random syntax tree of at most depth levels, using only
+// the syntax shared by rek and package regexp.
+func (g *nodeGenerator) generate(depth int) *Node {
+	kind := g.next(8)
+	if depth == 0 || len(g.data) == 0 {
+		kind %= 3
+	}
+	switch kind {
+	case 0:
+		return &Node{Kind: NodeLiteral, Rune: fuzzRunes[g.next(len(fuzzRunes))]}
+	case 1:
+		return &Node{Kind: NodeAny}
+	case 2:
+		n := &Node{Kind: NodeCharClass, Negated: g.next(4) == 0}
+		for i := g.next(3); i >= 0; i-- {
+			a, b := fuzzRunes[g.next(len(fuzzRunes))], fuzzRunes[g.next(len(fuzzRunes))]
+			if a > b {
+				a, b = b, a
+			}
+			n.Ranges = append(n.Ranges, [2]rune{a, b})
+		}
+		return n
+	case 3, 4:
+		n := &Node{Kind: NodeConcat}
+		for i := g.next(3); i >= 0; i-- {
+			n.Subs = append(n.Subs, g.generate(depth-1))
+		}
+		return n
+	case 5:
+		n := &Node{Kind: NodeAlternate}
+		for i := g.next(3); i >= 0; i-- {
+			n.Subs = append(n.Subs, g.generate(depth-1))
+		}
+		return n
+	default:
+		bounds := [][2]int{{0, -1}, {1, -1}, {0, 1}}[g.next(3)]
+		return &Node{Kind: NodeRepeat, Min: bounds[0], Max: bounds[1], Subs: []*Node{g.generate(depth - 1)}}
+	}
+}
+
+// syntaxOf returns the node in the syntax of package regexp.
+func syntaxOf(n *Node) string {
+	switch n.Kind {
+	case NodeLiteral:
+		return regexp.QuoteMeta(string(n.Rune))
+	case NodeAny:
+		return "."
+	case NodeCharClass:
+		var sb strings.Builder
+		sb.WriteString("[")
+		if n.Negated {
+			sb.WriteString("^")
+		}
+		for _, r := range n.Ranges {
+			sb.WriteString(fmt.Sprintf(`\x{%x}-\x{%x}`, r[0], r[1]))
+		}
+		sb.WriteString("]")
+		return sb.String()
+	case NodeConcat, NodeAlternate:
+		var subs []string
+		for _, s := range n.Subs {
+			subs = append(subs, "(?:"+syntaxOf(s)+")")
+		}
+		if n.Kind == NodeConcat {
+			return strings.Join(subs, "")
+		}
+		return strings.Join(subs, "|")
+	case NodeRepeat:
+		op := map[[2]int]string{{0, -1}: "*", {1, -1}: "+", {0, 1}: "?"}[[2]int{n.Min, n.Max}]
+		return "(?:" + syntaxOf(n.Subs[0]) + ")" + op
+	}
+	panic("unexpected node")
+}
+
+// randomInput returns a random string of fuzzRunes.
+func randomInput(rng *rand.Rand) string {
+	var sb strings.Builder
+	for i := rng.Intn(8); i > 0; i-- {
+		sb.WriteRune(fuzzRunes[rng.Intn(len(fuzzRunes))])
+	}
+	return sb.String()
+}
+
+// checkAgainstRegexp compiles a random pattern generated from data, and checks
+// that it agrees with package regexp on the inputs.
+func checkAgainstRegexp(t *testing.T, data []byte, inputs []string) {
+	g := &nodeGenerator{data}
+	node := g.generate(4)
+	pattern := node.String()
+	std := regexp.MustCompile(`^(?:` + syntaxOf(node) + `)$`)
+
+	// DFAs can be exponentially large, which would hang the fuzzer
+	r, err := CompileWithOptions(pattern, fuzzOptions)
+	if _, ok := err.(*ErrTooComplex); ok {
+		t.Skip(err)
+	}
+	if std.MatchString("") {
+		if err == nil || err.Error() != "empty string is accepted by this NFA" {
+			t.Fatalf("Compile(%q) returns %v, want the empty string error", pattern, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Compile(%q) fails: %v", pattern, err)
+	}
+
+	rng := rand.New(rand.NewSource(int64(len(data))))
+	for i := 0; i < 5; i++ {
+		if s, ok := r.Generate(rng, GenerateOptions{MaxLen: 8}); ok {
+			inputs = append(inputs, s)
+		}
+		inputs = append(inputs, randomInput(rng))
+	}
+	for _, s := range inputs {
+		if got, want := r.Match(s), std.MatchString(s); got != want {
+			t.Fatalf("Compile(%q).Match(%q) = %v, want %v (regexp %s)", pattern, s, got, want, std)
+		}
+	}
+}
+
+func FuzzCompile(f *testing.F) {
+	f.Add([]byte{3, 0, 1, 7, 0, 2})
+	f.Add([]byte{5, 2, 2, 0, 3, 1, 7, 6, 0, 4, 2})
+	f.Add([]byte("rek fuzzing seed"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkAgainstRegexp(t, data, nil)
+	})
+}
+
+func FuzzMatch(f *testing.F) {
+	f.Add([]byte{3, 0, 1, 7, 0, 2}, "ab")
+	f.Add([]byte{6, 2, 1, 3, 5}, "é\n.")
+	f.Fuzz(func(t *testing.T, data []byte, input string) {
+		checkAgainstRegexp(t, data, []string{input})
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range []string{"(a*|b*)[0-9]?", "~(.*a)&[^\\]]+", "a(", "[z-a]", "\\"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, pattern string) {
+		n, err := Parse(pattern)
+		if err != nil {
+			if _, ok := err.(*SyntaxError); !ok {
+				t.Fatalf("Parse(%q) returns %T", pattern, err)
+			}
+			return
+		}
+		// the canonical form must be parsed into the same form
+		m, err := Parse(n.String())
+		if err != nil {
+			t.Fatalf("Parse(%q) fails: %v", n.String(), err)
+		}
+		if m.String() != n.String() {
+			t.Fatalf("canonical form of %q is %q, then %q", pattern, n.String(), m.String())
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"math/big"
+	"math/rand"
+)
+
+// GenerateOptions controls the length of strings produced by Generate.
+type GenerateOptions struct {
+	// MinLen is the length in runes that generated strings try to reach
+	// before stopping. It is not guaranteed if the pattern matches only
+	// shorter strings.
+	MinLen int
+	// MaxLen is the maximum length in runes of generated strings. Zero means 16.
+	MaxLen int
+	// StopRate is the probability of stopping at each end state once MinLen
+	// is reached. Zero means 0.25.
+	StopRate float64
+}
+
+// Generate returns a random string matched by re. The walk on the DFA only
+// enters states from which an end state can be reached within MaxLen runes,
+// so it never dead-ends. It returns false if no string of at most MaxLen runes
+// is matched by re.
+func (re *REK) Generate(rng *rand.Rand, opts GenerateOptions) (string, bool) {
+	if opts.MaxLen == 0 {
+		opts.MaxLen = 16
+	}
+	if opts.StopRate == 0 {
+		opts.StopRate = 0.25
+	}
+	dist := re.d.distanceToEnd()
+	if dist[0] == -1 || dist[0] > opts.MaxLen {
+		return "", false
+	}
+
+	var input []rune
+	var choices []dfaTransfer
+	for state := 0; ; {
+		s := re.d.states[state]
+		rest := opts.MaxLen - len(input)
+		choices = choices[:0]
+		for _, t := range s.transfers {
+			if dist[t.target] != -1 && dist[t.target] < rest && runeCount(t.lower, t.upper) > 0 {
+				choices = append(choices, t)
+			}
+		}
+		if s.isEnd && (len(choices) == 0 ||
+			len(input) >= opts.MinLen && rng.Float64() < opts.StopRate) {
+			return string(input), true
+		}
+		t := choices[rng.Intn(len(choices))]
+		input = append(input, randomRune(rng, t.lower, t.upper))
+		state = t.target
+	}
+}
+
+// GenerateRejected returns a random string not matched by re. See Generate for
+// the meaning of the options.
+func (re *REK) GenerateRejected(rng *rand.Rand, opts GenerateOptions) (string, bool) {
+	c := Complement(re)
+	return c.Generate(rng, opts)
+}
+
+// GenerateUniform returns a string chosen uniformly at random among all
+// strings of exactly length runes matched by re. It returns false if there is
+// no such string.
+func (re *REK) GenerateUniform(rng *rand.Rand, length int) (string, bool) {
+	if length < 0 {
+		return "", false
+	}
+	count := re.d.countTable(length)
+	if count[length][0].Sign() == 0 {
+		return "", false
+	}
+
+	input := make([]rune, 0, length)
+	x := new(big.Int).Rand(rng, count[length][0])
+	for state, rest := 0, length; rest > 0; rest-- {
+		// pick the transfer and the rune which the x-th string goes through
+		for _, t := range re.d.states[state].transfers {
+			n := count[rest-1][t.target]
+			w := new(big.Int).Mul(big.NewInt(runeCount(t.lower, t.upper)), n)
+			if x.Cmp(w) >= 0 {
+				x.Sub(x, w)
+				continue
+			}
+			k := new(big.Int)
+			x.DivMod(x, n, k)
+			input = append(input, nthRune(t.lower, x.Int64()))
+			x = k
+			state = t.target
+			break
+		}
+	}
+	return string(input), true
+}
+
+// distanceToEnd returns for every state the minimum number of runes needed to
+// reach an end state, or -1 if no end state is reachable.
+func (d *dfa) distanceToEnd() []int {
+	from := make([][]int, len(d.states))
+	dist := make([]int, len(d.states))
+	var queue []int
+	for i, s := range d.states {
+		for _, t := range s.transfers {
+			if runeCount(t.lower, t.upper) > 0 {
+				from[t.target] = append(from[t.target], i)
+			}
+		}
+		dist[i] = -1
+		if s.isEnd {
+			dist[i] = 0
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, q := range from[p] {
+			if dist[q] == -1 {
+				dist[q] = dist[p] + 1
+				queue = append(queue, q)
+			}
+		}
+	}
+	return dist
+}
+
+// randomRune returns a random rune in [lower, upper] that is not a surrogate.
+func randomRune(rng *rand.Rand, lower, upper rune) rune {
+	return nthRune(lower, rng.Int63n(runeCount(lower, upper)))
+}
+
+// nthRune returns the n-th (starting from 0) rune that is not a surrogate,
+// counting from lower.
+func nthRune(lower rune, n int64) rune {
+	r := rune(int64(lower) + n)
+	if lower < 0xd800 && 0xd800 <= r || 0xd800 <= lower && lower <= 0xdfff {
+		// skip the surrogates
+		if lower < 0xd800 {
+			r += 0x800
+		} else {
+			r = rune(0xe000 + n)
+		}
+	}
+	return r
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGenerate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, re := range []string{
+		"(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)",
+		"[^a]+&~(.*[\ud7ff-\ue000].*)",
+		"x(y|z)*",
+	} {
+		r := Compile(re)
+		for i := 0; i < 100; i++ {
+			opts := GenerateOptions{MinLen: 3, MaxLen: 8}
+			s, ok := r.Generate(rng, opts)
+			if !ok || !r.Match(s) || utf8.RuneCountInString(s) > opts.MaxLen {
+				t.Fatalf("%s: Generate = %q, %v", re, s, ok)
+			}
+			s, ok = r.GenerateRejected(rng, opts)
+			if !ok || r.Match(s) {
+				t.Fatalf("%s: GenerateRejected = %q, %v", re, s, ok)
+			}
+			s, ok = r.GenerateUniform(rng, 4)
+			if !ok || !r.Match(s) || utf8.RuneCountInString(s) != 4 {
+				t.Fatalf("%s: GenerateUniform = %q, %v", re, s, ok)
+			}
+		}
+	}
+
+	r := Compile("a|b|c|de")
+	if s, ok := r.Generate(rng, GenerateOptions{MaxLen: 1, StopRate: 1}); !ok || len(s) != 1 {
+		t.Errorf("Generate = %q, %v", s, ok)
+	}
+	seen := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		s, _ := r.GenerateUniform(rng, 1)
+		seen[s]++
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		if seen[s] < 900 || seen[s] > 1100 {
+			t.Errorf("GenerateUniform is not uniform: %v", seen)
+		}
+	}
+	if _, ok := r.GenerateUniform(rng, 3); ok {
+		t.Errorf("GenerateUniform(3) succeeded")
+	}
+	if _, ok := r.GenerateUniform(rng, -1); ok {
+		t.Errorf("GenerateUniform(-1) succeeded")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import "unicode/utf8"
+
+// GlobOptions are options of CompileGlob.
+type GlobOptions struct {
+	// Separator separates segments of paths, which is / if it's 0.
+	Separator rune
+}
+
+// CompileGlob compiles a glob pattern matching paths. In the pattern, * matches
+// any string in a single segment, ? matches any rune but the separator, [...]
+// and [!...] (or [^...]) are character classes never matching the separator,
+// {a,b} matches either a or b, and \ escapes the next rune. A ** forming a whole segment matches zero or
+// more segments, so a/**/b matches a/b and a/x/y/b, while a/** matches
+// everything inside a. Elsewhere ** is the same as *.
+//
+// Unlike Compile, patterns matching the empty string are accepted.
+func CompileGlob(pattern string, opts GlobOptions) (r REK, err error) {
+	defer catchSyntaxError(&err)
+	sep := opts.Separator
+	if sep == 0 {
+		sep = '/'
+	}
+	g := &globParser{newParseHelper(pattern), []rune(pattern), 0, sep}
+	n := g.sequence(false, true)
+	return REK{noLimits.compileNode(n)}, nil
+}
+
+// CompileLike compiles a pattern of the LIKE operator in SQL, where % matches
+// any string and _ matches any rune. The escape rune makes the following %, _
+// or escape rune itself a literal, and no rune is the escape rune if escape is
+// 0. Like CompileGlob, patterns matching the empty string are accepted.
+func CompileLike(pattern string, escape rune) (r REK, err error) {
+	defer catchSyntaxError(&err)
+	re := []rune(pattern)
+	h := newParseHelper(pattern)
+	n := &Node{Kind: NodeConcat, Span: Span{0, len(pattern)}}
+	for i := 0; i < len(re); i++ {
+		start := i
+		var sub *Node
+		switch {
+		case escape != 0 && re[i] == escape:
+			i++
+			if i == len(re) || re[i] != '%' && re[i] != '_' && re[i] != escape {
+				h.fail("invalid escape", start)
+			}
+			sub = &Node{Kind: NodeLiteral, Rune: re[i]}
+		case re[i] == '%':
+			sub = &Node{Kind: NodeRepeat, Min: 0, Max: -1, Subs: []*Node{anyRuneNode()}}
+		case re[i] == '_':
+			sub = anyRuneNode()
+		default:
+			sub = &Node{Kind: NodeLiteral, Rune: re[i]}
+		}
+		sub.Span = Span{h.offset[start], h.offset[i+1]}
+		n.Subs = append(n.Subs, sub)
+	}
+	if len(n.Subs) == 0 {
+		n = &Node{Kind: NodeEmpty}
+	}
+	return REK{noLimits.compileNode(n)}, nil
+}
+
+// anyRuneNode returns a node matching any rune, including new lines.
+func anyRuneNode() *Node {
+	return &Node{Kind: NodeCharClass, Ranges: [][2]rune{{0, utf8.MaxRune}}}
+}
+
+// globParser helps parse glob patterns.
+type globParser struct {
+	h   *parseHelper
+	re  []rune
+	i   int // index of the next rune
+	sep rune
+}
+
+// sequence parses the pattern until the end, or the end of the current
+// alternative if inBraces is true, and returns the concatenation.
+// atSegmentStart is whether the sequence starts a segment, and is updated to
+// whether the i-th rune does.
+func (g *globParser) sequence(inBraces, atSegmentStart bool) *Node {
+	re := g.re
+	n := &Node{Kind: NodeConcat}
+	first := g.i
+	for g.i < len(re) {
+		start := g.i
+		var sub *Node
+		switch c := re[g.i]; {
+		case inBraces && (c == ',' || c == '}'):
+			return g.finishSequence(n, first)
+		case c == '\\':
+			g.i++
+			if g.i == len(re) {
+				g.h.fail("trailing backslash", start)
+			}
+			sub = &Node{Kind: NodeLiteral, Rune: re[g.i]}
+		case c == '?':
+			sub = g.notSeparator()
+		case c == '*':
+			for g.i+1 < len(re) && re[g.i+1] == '*' {
+				g.i++
+			}
+			isSegment := g.i > start && atSegmentStart
+			switch {
+			case isSegment && g.i+1 == len(re):
+				// anything, including separators
+				sub = &Node{Kind: NodeRepeat, Min: 0, Max: -1, Subs: []*Node{anyRuneNode()}}
+			case isSegment && re[g.i+1] == g.sep:
+				// zero or more segments, each followed by a separator
+				g.i++
+				all := &Node{Kind: NodeRepeat, Min: 0, Max: -1, Subs: []*Node{anyRuneNode()}}
+				seg := &Node{Kind: NodeConcat, Subs: []*Node{all, {Kind: NodeLiteral, Rune: g.sep}}}
+				sub = &Node{Kind: NodeRepeat, Min: 0, Max: 1, Subs: []*Node{seg}}
+			default:
+				sub = &Node{Kind: NodeRepeat, Min: 0, Max: -1, Subs: []*Node{g.notSeparator()}}
+			}
+		case c == '[':
+			sub = g.class()
+		case c == '{':
+			g.i++
+			sub = &Node{Kind: NodeAlternate}
+			for {
+				// every alternative starts where the braces do
+				sub.Subs = append(sub.Subs, g.sequence(true, atSegmentStart))
+				if g.i == len(re) {
+					g.h.fail("missing closing brace", start)
+				}
+				if re[g.i] == '}' {
+					break
+				}
+				g.i++
+			}
+		default:
+			sub = &Node{Kind: NodeLiteral, Rune: c}
+		}
+		atSegmentStart = re[g.i] == g.sep
+		g.i++
+		sub.Span = Span{g.h.offset[start], g.h.offset[g.i]}
+		n.Subs = append(n.Subs, sub)
+	}
+	return g.finishSequence(n, first)
+}
+
+// finishSequence returns the concatenation of a sequence starting at the
+// first rune, or the empty string if it's empty.
+func (g *globParser) finishSequence(n *Node, first int) *Node {
+	n.Span = Span{g.h.offset[first], g.h.offset[g.i]}
+	switch len(n.Subs) {
+	case 0:
+		n.Kind = NodeEmpty
+	case 1:
+		return n.Subs[0]
+	}
+	return n
+}
+
+// withoutSeparator returns ranges with the separator removed from them.
+func (g *globParser) withoutSeparator(ranges [][2]rune) [][2]rune {
+	var result [][2]rune
+	for _, r := range ranges {
+		if r[0] <= g.sep && g.sep <= r[1] {
+			if r[0] < g.sep {
+				result = append(result, [2]rune{r[0], g.sep - 1})
+			}
+			if g.sep < r[1] {
+				result = append(result, [2]rune{g.sep + 1, r[1]})
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// notSeparator returns a node matching any rune but the separator.
+func (g *globParser) notSeparator() *Node {
+	return &Node{Kind: NodeCharClass, Negated: true, Ranges: [][2]rune{{g.sep, g.sep}}}
+}
+
+// class parses a character class starting at the i-th rune, and leaves i at
+// the closing bracket. A ] at the beginning and a - at the beginning or the
+// end are literals. Like * and ?, a class never matches the separator.
+func (g *globParser) class() *Node {
+	re := g.re
+	start := g.i
+	g.i++
+	n := &Node{Kind: NodeCharClass}
+	if g.i < len(re) && (re[g.i] == '!' || re[g.i] == '^') {
+		n.Negated = true
+		n.Ranges = append(n.Ranges, [2]rune{g.sep, g.sep})
+		g.i++
+	}
+
+	getChar := func() rune {
+		if re[g.i] == '\\' {
+			g.i++
+		}
+		if g.i == len(re) {
+			g.h.fail("missing closing bracket", start)
+		}
+		g.i++
+		return re[g.i-1]
+	}
+
+	for first := true; ; first = false {
+		if g.i == len(re) {
+			g.h.fail("missing closing bracket", start)
+		}
+		if re[g.i] == ']' && !first {
+			if !n.Negated {
+				n.Ranges = g.withoutSeparator(n.Ranges)
+			}
+			return n
+		}
+		j := g.i
+		ch1 := getChar()
+		if g.i+1 < len(re) && re[g.i] == '-' && re[g.i+1] != ']' {
+			g.i++
+			ch2 := getChar()
+			if ch1 > ch2 {
+				g.h.fail("illegal range", j)
+			}
+			n.Ranges = append(n.Ranges, [2]rune{ch1, ch2})
+		} else {
+			n.Ranges = append(n.Ranges, [2]rune{ch1, ch1})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestCompileGlob(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		sep      rune
+		accepted []string
+		rejected []string
+	}{
+		{"*.go", 0, []string{"a.go", ".go"}, []string{"a/b.go", "a.goo"}},
+		{"src/?a[!b]/*", 0, []string{"src/xac/", "src/xac/d.go"}, []string{"src/xab/d.go", "src//a/d", "src/xac/d/e"}},
+		{"**/*.{c,h,}", 0, []string{"a.c", "x/y/a.h", "b."}, []string{"a.go", "/"}},
+		{"a/**/b", 0, []string{"a/b", "a/x/b", "a/x/y/b"}, []string{"ab", "a/xb", "b"}},
+		{"a/**", 0, []string{"a/", "a/x", "a/x/y"}, []string{"a", "ab/x"}},
+		{"**", 0, []string{"", "a", "a/b/c"}, nil},
+		{"a**b", 0, []string{"ab", "axxb"}, []string{"a/b"}},
+		{"{a,b{c,d}}x", 0, []string{"ax", "bcx", "bdx"}, []string{"bx", "abx"}},
+		{"foo{**/x,y}", 0, []string{"fooa/x", "foo/x", "fooy"}, []string{"foox", "fooa/b/x"}},
+		{"a/[.-0]b", 0, []string{"a/.b", "a/0b"}, []string{"a//b", "a/b"}},
+		{"a[/]b", 0, nil, []string{"a/b", "ab"}},
+		{"foo/{**/x,y}", 0, []string{"foo/x", "foo/a/b/x", "foo/y"}, []string{"foox", "foo/a/y"}},
+		{"[]a-]\\*\\{[a\\]]", 0, []string{"]*{a", "-*{]"}, []string{"b*{a", "]\\*{a"}},
+		{"*.txt", '\\', []string{"a.txt"}, []string{"a\\b.txt"}},
+		{"c:**:\\*", ':', []string{"c:*", "c:a:b:*", "c:a/b:*"}, []string{"c*", "c:x", "c:a*"}},
+	}
+	for _, c := range cases {
+		r, err := CompileGlob(c.pattern, GlobOptions{Separator: c.sep})
+		if err != nil {
+			t.Errorf("CompileGlob(%q): %v", c.pattern, err)
+			continue
+		}
+		for _, s := range c.accepted {
+			if !r.Match(s) {
+				t.Errorf("glob %q rejects %q", c.pattern, s)
+			}
+		}
+		for _, s := range c.rejected {
+			if r.Match(s) {
+				t.Errorf("glob %q accepts %q", c.pattern, s)
+			}
+		}
+	}
+
+	for _, p := range []string{"a\\", "[a", "[]", "[z-a]", "{a,b", "a{b{c}"} {
+		if _, err := CompileGlob(p, GlobOptions{}); err == nil {
+			t.Errorf("CompileGlob(%q) succeeded", p)
+		}
+	}
+}
+
+func TestCompileLike(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		escape   rune
+		accepted []string
+		rejected []string
+	}{
+		{"user_%", 0, []string{"users", "user_1", "users\nx"}, []string{"user", "use_1"}},
+		{"100!%!_!!%", '!', []string{"100%_!", "100%_!x"}, []string{"100%a!", "100%_"}},
+		{"%\\%", '\\', []string{"%", "50%"}, []string{"50"}},
+		{"", 0, []string{""}, []string{"a"}},
+	}
+	for _, c := range cases {
+		r, err := CompileLike(c.pattern, c.escape)
+		if err != nil {
+			t.Errorf("CompileLike(%q): %v", c.pattern, err)
+			continue
+		}
+		for _, s := range c.accepted {
+			if !r.Match(s) {
+				t.Errorf("LIKE %q rejects %q", c.pattern, s)
+			}
+		}
+		for _, s := range c.rejected {
+			if r.Match(s) {
+				t.Errorf("LIKE %q accepts %q", c.pattern, s)
+			}
+		}
+	}
+
+	for _, p := range []string{"a!", "a!b"} {
+		if _, err := CompileLike(p, '!'); err == nil {
+			t.Errorf("CompileLike(%q) succeeded", p)
+		}
+	}
+}
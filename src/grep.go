@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// maximum length of lines read by "rek grep"
+const maxLineLength = 64 << 20
+
+// grepOptions are the flags of "rek grep".
+type grepOptions struct {
+	invert     bool // select lines not matched
+	count      bool // print the number of selected lines only
+	lineNumber bool // prefix lines with line numbers
+	filesOnly  bool // print names of files with selected lines only
+	onlyMatch  bool // print matched parts of lines only
+	recursive  bool // walk directories
+	wholeLine  bool // match whole lines rather than substrings
+}
+
+// grepper filters lines of files by a REK.
+type grepper struct {
+	re       REK
+	opts     grepOptions
+	out      io.Writer
+	withName bool // prefix lines with file names
+	matched  bool // whether any line is selected
+	failed   bool // whether any file can't be read
+}
+
+// runGrep implements "rek grep". It exits with 0 if any line is selected, 1 if
+// none is selected, and 2 on error.
+func runGrep(args []string) int {
+	var opts grepOptions
+	fs := flag.NewFlagSet("grep", flag.ContinueOnError)
+	fs.BoolVar(&opts.invert, "v", false, "select lines not matched")
+	fs.BoolVar(&opts.count, "c", false, "print the number of selected lines only")
+	fs.BoolVar(&opts.lineNumber, "n", false, "prefix lines with line numbers")
+	fs.BoolVar(&opts.filesOnly, "l", false, "print names of files with selected lines only")
+	fs.BoolVar(&opts.onlyMatch, "o", false, "print matched parts of lines only")
+	fs.BoolVar(&opts.recursive, "r", false, "search directories recursively")
+	fs.BoolVar(&opts.wholeLine, "x", false, "match whole lines rather than substrings")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	r, err := compilePattern(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rek:", err)
+		return 2
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	g := &grepper{re: r, opts: opts, out: out}
+	names := fs.Args()[1:]
+	if len(names) == 0 && opts.recursive {
+		names = []string{"."}
+	}
+	g.withName = len(names) > 1 || opts.recursive
+	if len(names) == 0 {
+		g.grepReader(os.Stdin, "(standard input)")
+	}
+	for _, name := range names {
+		g.grepPath(name)
+	}
+
+	switch {
+	case g.failed:
+		return 2
+	case g.matched:
+		return 0
+	}
+	return 1
+}
+
+// grepPath filters lines of a file, or files in a directory if the recursive
+// flag is set, where - is the standard input.
+func (g *grepper) grepPath(name string) {
+	if name == "-" {
+		g.grepReader(os.Stdin, "(standard input)")
+		return
+	}
+	if !g.opts.recursive {
+		g.grepFile(name)
+		return
+	}
+	err := filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			g.fail(err)
+		} else if info.Mode().IsRegular() {
+			g.grepFile(path)
+		}
+		return nil
+	})
+	if err != nil {
+		g.fail(err)
+	}
+}
+
+// grepFile filters lines of a file.
+func (g *grepper) grepFile(name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		g.fail(err)
+		return
+	}
+	defer f.Close()
+	g.grepReader(f, name)
+}
+
+// fail reports an error, and lets the program exit with 2.
+func (g *grepper) fail(err error) {
+	fmt.Fprintln(os.Stderr, "rek:", err)
+	g.failed = true
+}
+
+// grepReader filters lines read from r, where name is printed before lines if
+// necessary.
+func (g *grepper) grepReader(r io.Reader, name string) {
+	prefix := ""
+	if g.withName {
+		prefix = name + ":"
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	var count int
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		var isMatched bool
+		if g.opts.wholeLine {
+			isMatched = g.re.Match(line)
+		} else {
+			isMatched = g.re.Contains(line)
+		}
+		if isMatched == g.opts.invert {
+			continue
+		}
+		count++
+		if g.opts.filesOnly {
+			break
+		}
+		if g.opts.count {
+			continue
+		}
+
+		linePrefix := prefix
+		if g.opts.lineNumber {
+			linePrefix += fmt.Sprintf("%d:", n)
+		}
+		switch {
+		case !g.opts.onlyMatch:
+			fmt.Fprintf(g.out, "%s%s\n", linePrefix, line)
+		case g.opts.invert:
+			// lines not matched have no matched parts
+		case g.opts.wholeLine:
+			fmt.Fprintf(g.out, "%s%s\n", linePrefix, line)
+		default:
+			for _, m := range g.re.FindAllStringIndex(line, -1) {
+				if m[0] < m[1] {
+					fmt.Fprintf(g.out, "%s%s\n", linePrefix, line[m[0]:m[1]])
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		g.fail(fmt.Errorf("%s: %v", name, err))
+	}
+
+	g.matched = g.matched || count > 0
+	switch {
+	case g.opts.filesOnly && count > 0:
+		fmt.Fprintln(g.out, name)
+	case g.opts.count && !g.opts.filesOnly:
+		fmt.Fprintf(g.out, "%s%d\n", prefix, count)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rek")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "b.txt")
+	os.Mkdir(filepath.Dir(b), 0755)
+	ioutil.WriteFile(a, []byte("id 12\nname\n7 and 45\n"), 0644)
+	ioutil.WriteFile(b, []byte("x\n"), 0644)
+
+	cases := []struct {
+		opts  grepOptions
+		paths []string
+		want  string
+	}{
+		{grepOptions{}, []string{a}, "id 12\n7 and 45\n"},
+		{grepOptions{lineNumber: true, onlyMatch: true}, []string{a}, "1:12\n3:7\n3:45\n"},
+		{grepOptions{invert: true}, []string{a}, "name\n"},
+		{grepOptions{count: true}, []string{a, b}, a + ":2\n" + b + ":0\n"},
+		{grepOptions{filesOnly: true, recursive: true}, []string{dir}, a + "\n"},
+		{grepOptions{wholeLine: true}, []string{a}, ""},
+	}
+	for i, c := range cases {
+		var out bytes.Buffer
+		g := &grepper{re: Compile("[0-9]+"), opts: c.opts, out: &out, withName: len(c.paths) > 1 || c.opts.recursive}
+		for _, p := range c.paths {
+			g.grepPath(p)
+		}
+		if out.String() != c.want || g.failed || g.matched != (c.want != "") {
+			t.Errorf("case %d: output %q, matched %v", i, out.String(), g.matched)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrTooComplex is the error returned when compiling a pattern exceeds one of
+// the limits in CompileOptions.
+type ErrTooComplex struct {
+	Limit string // name of the field in CompileOptions, like "MaxDFAStates"
+	Max   int64  // value of the limit
+}
+
+func (e *ErrTooComplex) Error() string {
+	return fmt.Sprintf("pattern too complex: %s of %d exceeded", e.Limit, e.Max)
+}
+
+// compileAborted is panicked with when compiling is aborted by a limit or by
+// cancellation, and recovered into err by catchCompileError.
+type compileAborted struct {
+	err error
+}
+
+// catchCompileError recovers a panicking *SyntaxError or compileAborted into
+// err. It must be called by defer.
+func catchCompileError(err *error) {
+	if x := recover(); x != nil {
+		switch e := x.(type) {
+		case *SyntaxError:
+			*err = e
+		case compileAborted:
+			*err = e.err
+		default:
+			panic(x)
+		}
+	}
+}
+
+// compileLimits bounds the resources used by compiling a pattern.
+type compileLimits struct {
+	ctx  context.Context
+	opts CompileOptions
+}
+
+// noLimits lets compiling use unlimited resources.
+var noLimits = &compileLimits{ctx: context.Background()}
+
+// check aborts compiling if the context is done.
+func (l *compileLimits) check() {
+	if err := l.ctx.Err(); err != nil {
+		panic(compileAborted{err})
+	}
+}
+
+// checkLimit aborts compiling if value exceeds max, where 0 means no limit.
+func (l *compileLimits) checkLimit(limit string, value, max int64) {
+	if max > 0 && value > max {
+		panic(compileAborted{&ErrTooComplex{limit, max}})
+	}
+}
+
+// checkNFAStates aborts compiling if an NFA has too many states.
+func (l *compileLimits) checkNFAStates(states int) {
+	l.checkLimit("MaxNFAStates", int64(states), int64(l.opts.MaxNFAStates))
+}
+
+// checkDFAStates aborts compiling if a DFA has too many states.
+func (l *compileLimits) checkDFAStates(states int) {
+	l.checkLimit("MaxDFAStates", int64(states), int64(l.opts.MaxDFAStates))
+}
+
+// checkMemory aborts compiling if the estimated memory usage in bytes is too
+// large.
+func (l *compileLimits) checkMemory(bytes int64) {
+	l.checkLimit("MaxMemory", bytes, l.opts.MaxMemory)
+}
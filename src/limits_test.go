@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileLimits(t *testing.T) {
+	// the DFA of "(a|b)*a(a|b){10}" has 2^11 states
+	exponential := "(a|b)*a" + strings.Repeat("(a|b)", 10)
+	ere := func(opts CompileOptions) CompileOptions {
+		opts.Syntax = SyntaxERE
+		return opts
+	}
+	tests := []struct {
+		pattern string
+		opts    CompileOptions
+		limit   string // "" if no limit is exceeded
+	}{
+		{"abcdef", CompileOptions{MaxPatternLen: 6}, ""},
+		{"abcdefg", CompileOptions{MaxPatternLen: 6}, "MaxPatternLen"},
+		{"a{30}", ere(CompileOptions{MaxNFAStates: 100}), ""},
+		{"a{200}", ere(CompileOptions{MaxNFAStates: 100}), "MaxNFAStates"},
+		{"(a{10}){255}", ere(CompileOptions{MaxNFAStates: 100}), "MaxNFAStates"},
+		{strings.Repeat("a", 200), CompileOptions{MaxNFAStates: 100}, "MaxNFAStates"},
+		{exponential, CompileOptions{MaxDFAStates: 4096}, ""},
+		{exponential, CompileOptions{MaxDFAStates: 100}, "MaxDFAStates"},
+		{"~(a*b)&.*c" + exponential, CompileOptions{MaxDFAStates: 100}, "MaxDFAStates"},
+		{exponential, CompileOptions{MaxMemory: 16 << 20}, ""},
+		{exponential, CompileOptions{MaxMemory: 10000}, "MaxMemory"},
+		{"a{1,2}", ere(CompileOptions{MaxPatternLen: 5}), "MaxPatternLen"},
+		// the forward DFA is small, but the reverse one used by searching has
+		// 2^15 states
+		{strings.Repeat("[ab]", 14) + "a", CompileOptions{MaxDFAStates: 100, MaxMemory: 1 << 20}, "MaxDFAStates"},
+	}
+	for _, test := range tests {
+		r, err := CompileWithOptions(test.pattern, test.opts)
+		if test.limit == "" {
+			if err != nil {
+				t.Errorf("CompileWithOptions(%q, %+v) fails: %v", test.pattern, test.opts, err)
+			} else if r.Match("") || !r.Match(strings.Repeat("a", 30)) && !r.Match("abcdef") {
+				t.Errorf("CompileWithOptions(%q, %+v) is compiled wrongly", test.pattern, test.opts)
+			}
+			continue
+		}
+		e, ok := err.(*ErrTooComplex)
+		if !ok || e.Limit != test.limit {
+			t.Errorf("CompileWithOptions(%q, %+v) returns %v, want %s exceeded", test.pattern, test.opts, err, test.limit)
+		}
+	}
+
+	// syntax errors are still reported as syntax errors
+	if _, err := CompileWithOptions("(a", CompileOptions{MaxDFAStates: 1}); err == nil {
+		t.Errorf("CompileWithOptions(%q) succeeds", "(a")
+	} else if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("CompileWithOptions(%q) returns %T, want *SyntaxError", "(a", err)
+	}
+}
+
+func TestProductLimits(t *testing.T) {
+	abort := func(l *compileLimits, f func(l *compileLimits)) (err error) {
+		defer catchCompileError(&err)
+		f(l)
+		return nil
+	}
+	// the product of counters modulo 7 and 11 has 77 states and a dead state
+	a := constructDFA(constructNFA("(aaaaaaa)*"))
+	b := constructDFA(constructNFA("(aaaaaaaaaaa)*"))
+	and := func(x, y bool) bool { return x && y }
+	intersect := func(l *compileLimits) { l.product(a, b, and) }
+	if err := abort(&compileLimits{context.Background(), CompileOptions{MaxDFAStates: 78}}, intersect); err != nil {
+		t.Errorf("product within the limit fails: %v", err)
+	}
+	if err := abort(&compileLimits{context.Background(), CompileOptions{MaxDFAStates: 50}}, intersect); err == nil {
+		t.Error("product exceeding MaxDFAStates succeeds")
+	} else if e, ok := err.(*ErrTooComplex); !ok || e.Limit != "MaxDFAStates" {
+		t.Errorf("product exceeding MaxDFAStates returns %v", err)
+	}
+	if err := abort(&compileLimits{context.Background(), CompileOptions{MaxMemory: 1000}}, intersect); err == nil {
+		t.Error("product exceeding MaxMemory succeeds")
+	} else if e, ok := err.(*ErrTooComplex); !ok || e.Limit != "MaxMemory" {
+		t.Errorf("product exceeding MaxMemory returns %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := abort(&compileLimits{ctx, CompileOptions{}}, func(l *compileLimits) {
+		l.complementNFA(constructNFA("ab"))
+	})
+	if err != context.Canceled {
+		t.Errorf("complementNFA with a canceled context returns %v", err)
+	}
+}
+
+func TestCompileContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CompileContext(ctx, "abc", CompileOptions{}); err != context.Canceled {
+		t.Errorf("CompileContext with a canceled context returns %v", err)
+	}
+
+	// the DFA has 2^21 states, which takes far longer than the timeout
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := CompileContext(ctx, "(a|b)*a"+strings.Repeat("(a|b)", 20), CompileOptions{})
+	if err != context.DeadlineExceeded {
+		t.Errorf("CompileContext with a timeout returns %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("CompileContext returns %v after the timeout", d)
+	}
+
+	if r, err := CompileContext(context.Background(), "abc", CompileOptions{}); err != nil || !r.Match("abc") {
+		t.Errorf("CompileContext(%q) = %v, %v", "abc", r, err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// LiteralOptions are options of CompileLiterals.
+type LiteralOptions struct {
+	// Search builds the Aho–Corasick automaton used by searching, like
+	// FindAllString and Contains, when compiling rather than on the first
+	// search.
+	Search bool
+}
+
+// CompileLiterals returns a REK matching any of words. The minimal DFA is
+// built directly from the sorted words, which is much faster than compiling
+// the alternation of them, and searching uses an Aho–Corasick automaton of
+// the words, so large word lists can be searched in linear time. Like inputs,
+// words are decoded as UTF-8.
+func CompileLiterals(words []string, opts LiteralOptions) (REK, error) {
+	runes := make([][]rune, len(words))
+	for i, w := range words {
+		if w == "" {
+			return REK{}, errors.New("empty string is accepted by this NFA")
+		}
+		runes[i] = []rune(w)
+	}
+	sortRunes(runes)
+
+	b := &trieBuilder{root: &trieState{id: -1}, register: map[string]*trieState{}}
+	for _, w := range runes {
+		b.add(w)
+	}
+	if len(b.root.next) > 0 {
+		b.replaceOrRegister(b.root)
+	}
+	d := b.dfa()
+	d.literal = true
+	if len(runes) == 1 {
+		d.required = usableRequired(words[0])
+	}
+	if opts.Search {
+		d.reverseDFA()
+	}
+	return REK{d}, nil
+}
+
+// sortRunes sorts words in lexicographic order of runes.
+func sortRunes(words [][]rune) {
+	sort.Slice(words, func(i, j int) bool {
+		a, b := words[i], words[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// trieState is a state of the DFA built by trieBuilder.
+type trieState struct {
+	isEnd bool
+	runes []rune // in ascending order
+	next  []*trieState
+	id    int // index in the register, or -1 if not registered
+}
+
+// trieBuilder builds the minimal DFA of words added in lexicographic order,
+// by the incremental construction of Daciuk et al. States on the path of the
+// last word added are not registered yet, and other states are unique in the
+// register, where equivalent states are merged.
+type trieBuilder struct {
+	root     *trieState
+	register map[string]*trieState
+}
+
+// add adds a word not less than the last one added.
+func (b *trieBuilder) add(word []rune) {
+	// follow the prefix shared with the last word
+	s, i := b.root, 0
+	for ; i < len(word); i++ {
+		last := len(s.runes) - 1
+		if last < 0 || s.runes[last] != word[i] {
+			break
+		}
+		s = s.next[last]
+	}
+	// the rest of the last word can't be changed any more
+	if len(s.next) > 0 {
+		b.replaceOrRegister(s)
+	}
+	for ; i < len(word); i++ {
+		t := &trieState{id: -1}
+		s.runes = append(s.runes, word[i])
+		s.next = append(s.next, t)
+		s = t
+	}
+	s.isEnd = true
+}
+
+// replaceOrRegister registers the states after the last transfer of s, or
+// replaces them with registered equivalent states.
+func (b *trieBuilder) replaceOrRegister(s *trieState) {
+	last := len(s.next) - 1
+	child := s.next[last]
+	if len(child.next) > 0 {
+		b.replaceOrRegister(child)
+	}
+	key := child.key()
+	if q, ok := b.register[key]; ok {
+		s.next[last] = q
+		return
+	}
+	child.id = len(b.register)
+	b.register[key] = child
+}
+
+// key returns a string identifying the equivalent states, where all the next
+// states are registered.
+func (s *trieState) key() string {
+	var buf []byte
+	if s.isEnd {
+		buf = append(buf, '!')
+	}
+	for i, r := range s.runes {
+		buf = strconv.AppendInt(buf, int64(r), 10)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(s.next[i].id), 10)
+		buf = append(buf, ',')
+	}
+	return string(buf)
+}
+
+// dfa converts the states to a DFA, numbering them in breadth-first order and
+// merging transfers of adjacent runes leading to the same state.
+func (b *trieBuilder) dfa() *dfa {
+	index := map[*trieState]int{b.root: 0}
+	queue := []*trieState{b.root}
+	d := &dfa{}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		var transfers []dfaTransfer
+		for i, r := range s.runes {
+			next, ok := index[s.next[i]]
+			if !ok {
+				next = len(index)
+				index[s.next[i]] = next
+				queue = append(queue, s.next[i])
+			}
+			if last := len(transfers) - 1; last >= 0 &&
+				transfers[last].target == next && transfers[last].upper+1 == r {
+				transfers[last].upper = r
+			} else {
+				transfers = append(transfers, dfaTransfer{next, r, r})
+			}
+		}
+		d.states = append(d.states, dfaState{s.isEnd, transfers})
+	}
+	return d
+}
+
+// acyclicWords returns the strings matched by the acyclic DFA d as runes.
+func acyclicWords(d *dfa) [][]rune {
+	var words [][]rune
+	var prefix []rune
+	var visit func(state int)
+	visit = func(state int) {
+		s := d.states[state]
+		if s.isEnd {
+			words = append(words, append([]rune(nil), prefix...))
+		}
+		for _, t := range s.transfers {
+			for r := t.lower; r <= t.upper; r++ {
+				prefix = append(prefix, r)
+				visit(t.target)
+				prefix = prefix[:len(prefix)-1]
+			}
+		}
+	}
+	visit(0)
+	return words
+}
+
+// buildAhoCorasick returns the Aho–Corasick automaton of the reversed words,
+// which is the reverse DFA used by searching. It's a trie of the reversed words
+// with failure links, where an end state is reached whenever the runes read so
+// far end with any of them.
+func buildAhoCorasick(words [][]rune) *dfa {
+	reversed := make([][]rune, len(words))
+	for i, w := range words {
+		r := make([]rune, len(w))
+		for j, ch := range w {
+			r[len(w)-1-j] = ch
+		}
+		reversed[i] = r
+	}
+	sortRunes(reversed)
+
+	// build the trie, where transfers are added in ascending order since the
+	// words are sorted
+	d := &dfa{states: []dfaState{{}}}
+	for _, w := range reversed {
+		state := 0
+		for _, ch := range w {
+			transfers := d.states[state].transfers
+			if last := len(transfers) - 1; last >= 0 && transfers[last].lower == ch {
+				state = transfers[last].target
+				continue
+			}
+			d.states[state].transfers = append(transfers, dfaTransfer{len(d.states), ch, ch})
+			d.states = append(d.states, dfaState{})
+			state = len(d.states) - 1
+		}
+		d.states[state].isEnd = true
+	}
+
+	// compute failure links in breadth-first order, so that the failure link
+	// of a state is computed before it's followed
+	fail := make([]int, len(d.states))
+	queue := []int{0}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, t := range d.states[state].transfers {
+			f := 0
+			if state != 0 {
+				// d.fail is not set yet, so nextState follows the trie only
+				f = fail[state]
+				for f != 0 && d.nextState(f, t.lower) == -1 {
+					f = fail[f]
+				}
+				if next := d.nextState(f, t.lower); next != -1 {
+					f = next
+				}
+			}
+			fail[t.target] = f
+			d.states[t.target].isEnd = d.states[t.target].isEnd || d.states[f].isEnd
+			queue = append(queue, t.target)
+		}
+	}
+	d.fail = fail
+	return d
+}
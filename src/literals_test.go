@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileLiterals(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		var words []string
+		for j := rng.Intn(20) + 1; j > 0; j-- {
+			var sb strings.Builder
+			for k := rng.Intn(5) + 1; k > 0; k-- {
+				sb.WriteRune([]rune("abcé.")[rng.Intn(5)])
+			}
+			words = append(words, sb.String())
+		}
+		quoted := make([]string, len(words))
+		for j, w := range words {
+			quoted[j] = QuoteMeta(w)
+		}
+		want := Compile(strings.Join(quoted, "|"))
+
+		for _, opts := range []LiteralOptions{{}, {Search: true}} {
+			r, err := CompileLiterals(words, opts)
+			if err != nil {
+				t.Fatalf("CompileLiterals(%q) fails: %v", words, err)
+			}
+			if equal, s := Equivalent(&r, &want); !equal {
+				t.Fatalf("CompileLiterals(%q) differs on %q", words, s)
+			}
+			// the DFA is built minimal
+			if got, min := len(r.d.states), len(minimizeDFA(want.d).states); got != min {
+				t.Errorf("CompileLiterals(%q) has %d states, want %d", words, got, min)
+			}
+			for j := 0; j < 5; j++ {
+				s := randomText(rng, "abcé.x", 20)
+				if got, want := r.FindAllStringIndex(s, -1), want.FindAllStringIndex(s, -1); !reflect.DeepEqual(got, want) {
+					t.Fatalf("CompileLiterals(%q).FindAllStringIndex(%q) = %v, want %v", words, s, got, want)
+				}
+				if got, want := r.Contains(s), want.Contains(s); got != want {
+					t.Fatalf("CompileLiterals(%q).Contains(%q) = %v, want %v", words, s, got, want)
+				}
+			}
+		}
+	}
+
+	r, err := CompileLiterals([]string{"he", "she", "his", "hers"}, LiteralOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.FindAllString("ushers and his", -1); !reflect.DeepEqual(got, []string{"she", "his"}) {
+		t.Errorf("FindAllString = %q", got)
+	}
+	// invalid UTF-8 in inputs is decoded as U+FFFD, so the word can't be
+	// searched for as bytes
+	for _, words := range [][]string{{"a\uFFFDb"}, {"a\uFFFDb", "c"}} {
+		r, err := CompileLiterals(words, LiteralOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Match("a\xffb") || !r.Contains("xa\xffby") {
+			t.Errorf("CompileLiterals(%q) doesn't match invalid UTF-8", words)
+		}
+	}
+	if _, err := CompileLiterals([]string{"a", ""}, LiteralOptions{}); err == nil {
+		t.Error("CompileLiterals with the empty string succeeds")
+	}
+}
+
+func TestCompileLiteralsLarge(t *testing.T) {
+	var words []string
+	for i := 0; i < 30000; i++ {
+		words = append(words, fmt.Sprintf("term%dx", i*7919%100000))
+	}
+	start := time.Now()
+	r, err := CompileLiterals(words, LiteralOptions{Search: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("CompileLiterals takes %v", d)
+	}
+	for _, w := range words[:100] {
+		if !r.Match(w) {
+			t.Errorf("%q is not matched", w)
+		}
+	}
+	if r.Match("term") || r.Match("term7919") {
+		t.Error("prefixes of words are matched")
+	}
+	text := "see term7919x and term15838x, not term123456x"
+	if got := r.FindAllString(text, -1); !reflect.DeepEqual(got, []string{"term7919x", "term15838x"}) {
+		t.Errorf("FindAllString(%q) = %q", text, got)
+	}
+}
+
+func BenchmarkCompileLiterals(b *testing.B) {
+	var words []string
+	for i := 0; i < 10000; i++ {
+		words = append(words, fmt.Sprintf("term%dx", i*7919%100000))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CompileLiterals(words, LiteralOptions{Search: true})
+	}
+}
+
+// randomText returns a random string of at most n runes from alphabet.
+func randomText(rng *rand.Rand, alphabet string, n int) string {
+	runes := []rune(alphabet)
+	var sb strings.Builder
+	for i := rng.Intn(n + 1); i > 0; i-- {
+		sb.WriteRune(runes[rng.Intn(len(runes))])
+	}
+	return sb.String()
+}
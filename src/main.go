@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: rek <command> [arguments]
+
+commands:
+  equiv PATTERN1 PATTERN2    check whether two patterns match the same strings
+  trace [-nfa] PATTERN INPUT print every step of matching the input
+  grep [-vcnlorx] PATTERN [FILE...]
+                             print lines containing matches of the pattern
+  repl                       explore patterns interactively
+  test FILE...               check patterns against examples in suite files
+`
+
+// commands maps subcommand names to their implementations. A command returns
+// the exit status of the program.
+var commands = map[string]func(args []string) int{
+	"equiv": runEquiv,
+	"trace": runTrace,
+	"grep":  runGrep,
+	"repl":  runRepl,
+	"test":  runTest,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(cmd(os.Args[2:]))
+}
+
+// cliOptions are the options of compiling patterns given on the command line,
+// whose limits make pathological patterns fail instead of exhausting memory.
+var cliOptions = CompileOptions{
+	MaxNFAStates: 1 << 16,
+	MaxDFAStates: 1 << 16,
+	MaxMemory:    256 << 20,
+}
+
+// compilePattern compiles a pattern given on the command line with cliOptions.
+func compilePattern(re string) (REK, error) {
+	r, _, err := compilePatternNFA(re)
+	return r, err
+}
+
+// compilePatternNFA is like compilePattern, but also returns the NFA the DFA
+// is constructed from.
+func compilePatternNFA(re string) (REK, *nfa, error) {
+	r, n, err := compileWithNFA(context.Background(), re, cliOptions)
+	if _, ok := err.(*ErrTooComplex); ok {
+		return REK{}, nil, fmt.Errorf("%q: %v", re, err)
+	} else if err != nil {
+		return REK{}, nil, fmt.Errorf("invalid pattern %q: %v", re, err)
+	}
+	return r, n, nil
+}
+
+// runEquiv implements "rek equiv". It exits with 0 if the patterns are
+// equivalent, 1 if they are not, and 2 on error.
+func runEquiv(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	a, err := compilePattern(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rek:", err)
+		return 2
+	}
+	b, err := compilePattern(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rek:", err)
+		return 2
+	}
+
+	equal, s := Equivalent(&a, &b)
+	if equal {
+		fmt.Println("equivalent")
+		return 0
+	}
+	if a.Match(s) {
+		fmt.Printf("not equivalent: %q is matched by the first pattern only\n", s)
+	} else {
+		fmt.Printf("not equivalent: %q is matched by the second pattern only\n", s)
+	}
+	return 1
+}
+
+// runTrace implements "rek trace". It prints every step of matching, and exits
+// with 0 if the input is matched, 1 if it's not, and 2 on error.
+func runTrace(args []string) int {
+	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
+	printNFA := fs.Bool("nfa", false, "print the NFA, whose states are referred by steps")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	r, n, err := compilePatternNFA(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rek:", err)
+		return 2
+	}
+	if *printNFA {
+		fmt.Print(convertNFAToString(n))
+	}
+
+	matched := r.Trace(fs.Arg(1), func(step TraceStep) {
+		fmt.Printf("offset %d %q: state %d %v", step.Offset, step.Rune, step.From, step.FromNFA)
+		if step.To == -1 {
+			fmt.Println(" -> dead")
+		} else {
+			class := classString([]rune{step.Lower}, []rune{step.Upper})
+			fmt.Printf(" -%s-> state %d %v\n", class, step.To, step.ToNFA)
+		}
+	})
+	if matched {
+		fmt.Println("matched")
+		return 0
+	}
+	fmt.Println("not matched")
+	return 1
+}
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"sort"
+	"sync"
+	"unsafe"
+)
+
 // dfaTransfer is a conditional transition between DFA states.
 type dfaTransfer struct {
 	target       int
@@ -12,35 +18,77 @@ type dfaState struct {
 	transfers []dfaTransfer
 }
 
-// dfa is a deterministic finite automaton.
+// dfa is a deterministic finite automaton. It must not be modified once
+// constructed, since it's shared by goroutines matching concurrently, so
+// anything built lazily must be guarded like reverse.
+//
+// constructDFA is deterministic: states are numbered in the breadth-first
+// order they are reached from the start state, and transfers of every state
+// are sorted by their ranges, so the same NFA always gives the same DFA.
 type dfa struct {
 	states []dfaState
+	// nfaStates are the indices of NFA states represented by each state, if
+	// the DFA is constructed from an NFA
+	nfaStates [][]int
+	// reverse is built lazily by reverseDFA for searching
+	reverseOnce sync.Once
+	reverse     *dfa
+	// required is a literal contained by every match, or "" if unknown, which
+	// is found in the syntax tree by compileNode
+	required string
+	// literalInfo is extracted lazily by literals for searching
+	literalsOnce sync.Once
+	literalInfo  *literalInfo
+	// anchorStart and anchorEnd report whether matches found by searching
+	// must begin at the beginning or end at the end of the input, as ^ and $
+	// of patterns compiled by CompileSyntax
+	anchorStart, anchorEnd bool
+	// literal reports whether the DFA is built by CompileLiterals, so that
+	// it's acyclic and its reverse is an Aho–Corasick automaton
+	literal bool
+	// fail are the failure links of an Aho–Corasick automaton, followed when
+	// a state has no transfer for the input, or nil for other DFAs
+	fail []int
 }
 
-// nextState returns next state according to current state and input character.
+// nextState returns next state according to current state and input character,
+// or -1 if there is none.
 func (d *dfa) nextState(state int, input rune) int {
-	area := d.states[state].transfers
-	left, right := 0, len(area)
-	for left < right {
-		middle := (left + right) / 2
-		if input < area[middle].lower {
-			right = middle
-		} else if area[middle].upper < input {
-			left = middle + 1
-		} else {
-			return area[middle].target
+	for {
+		area := d.states[state].transfers
+		left, right := 0, len(area)
+		for left < right {
+			middle := (left + right) / 2
+			if input < area[middle].lower {
+				right = middle
+			} else if area[middle].upper < input {
+				left = middle + 1
+			} else {
+				return area[middle].target
+			}
+		}
+		if d.fail == nil {
+			return -1
 		}
+		if state == 0 {
+			// the start state of an Aho–Corasick automaton loops on the
+			// other runes
+			return 0
+		}
+		state = d.fail[state]
 	}
-	return -1
 }
 
 // constructDFA receives NFA and outputs DFA.
 func constructDFA(n *nfa) *dfa {
-	h := constructDFAHelper(n)
+	return noLimits.constructDFA(n)
+}
+
+// constructDFA is like the function constructDFA, but aborts if the DFA has
+// too many states or uses too much memory, or if the context is done.
+func (l *compileLimits) constructDFA(n *nfa) *dfa {
+	h := constructDFAHelper(n, l)
 	h.addDFAState(h.closure[0])
-	if h.dfa.states[0].isEnd {
-		panic("empty string is accepted by this NFA")
-	}
 
 	type info struct {
 		state        int
@@ -50,28 +98,35 @@ func constructDFA(n *nfa) *dfa {
 	queue := []info{{0, h.lower[0], h.upper[0], h.target[0]}}
 	isVisited := map[int]bool{0: true}
 	for len(queue) > 0 {
+		l.check()
 		p := queue[0]
 		queue = queue[1:]
 
 		for i := range p.lower {
 			set := make([]bool, h.size)
-			dict := map[int]bool{}
+			isSource := make([]bool, h.size)
+			var sources []int // NFA states reached by the transfer
 			for _, s := range p.target[i] {
-				if !dict[s] {
+				if !isSource[s] {
 					for k, b := range h.closure[s] {
 						set[k] = set[k] || b
 					}
-					dict[s] = true
+					isSource[s] = true
+					sources = append(sources, s)
 				}
 			}
 			next := h.addDFAState(set)
 			h.dfa.states[p.state].transfers = append(h.dfa.states[p.state].transfers,
 				dfaTransfer{next, p.lower[i], p.upper[i]})
+			h.addMemory(int64(unsafe.Sizeof(dfaTransfer{})))
 
 			if !isVisited[next] {
 				var lower, upper []rune
 				var target [][]int
-				for k := range dict {
+				// merge choices in a fixed order, so that the DFA is the same
+				// on every run
+				sort.Ints(sources)
+				for _, k := range sources {
 					lower, upper, target = mergeNext(lower, upper, target, h.lower[k], h.upper[k], h.target[k])
 				}
 				queue = append(queue, info{next, lower, upper, target})
@@ -93,6 +148,8 @@ type dfaHelper struct {
 	dfsState     [][]bool
 	dfaStateId   map[int][]int
 	dfa          *dfa
+	limits       *compileLimits
+	memory       int64 // estimated memory usage in bytes
 }
 
 // dfaStateHash returns the hash value of a DFA state.
@@ -127,11 +184,26 @@ func (h *dfaHelper) addDFAState(set []bool) int {
 	}
 	h.dfsState = append(h.dfsState, set)
 	h.dfa.states = append(h.dfa.states, dfaState{set[len(set)-1], nil})
+	var indices []int
+	for i, b := range set {
+		if b {
+			indices = append(indices, i)
+		}
+	}
+	h.dfa.nfaStates = append(h.dfa.nfaStates, indices)
+	h.limits.checkDFAStates(len(h.dfa.states))
+	h.addMemory(int64(len(set)) + int64(unsafe.Sizeof(dfaState{})) + int64(len(indices))*int64(unsafe.Sizeof(0)))
 	return len(h.dfsState) - 1
 }
 
+// addMemory adds the estimated memory usage, and aborts if it's too large.
+func (h *dfaHelper) addMemory(bytes int64) {
+	h.memory += bytes
+	h.limits.checkMemory(h.memory)
+}
+
 // constructDFAHelper initializes a dfaHelper.
-func constructDFAHelper(n *nfa) *dfaHelper {
+func constructDFAHelper(n *nfa, l *compileLimits) *dfaHelper {
 	h := &dfaHelper{
 		size:       len(n.states),
 		nfaStateId: map[*nfaState]int{},
@@ -139,28 +211,29 @@ func constructDFAHelper(n *nfa) *dfaHelper {
 		m:          100000007,
 		dfaStateId: map[int][]int{},
 		dfa:        &dfa{},
+		limits:     l,
 	}
 	// number NFA states
 	for i, s := range n.states {
 		h.nfaStateId[s] = i
 	}
-	// calculate transitive closure (E(p))
+	// calculate transitive closure (E(p)) by searching along empty transfers
+	// from every state, which takes linear time for each state
+	h.addMemory(int64(h.size) * int64(h.size))
 	h.closure = make([][]bool, h.size)
 	for i := range h.closure {
+		l.check()
 		h.closure[i] = make([]bool, h.size)
 		h.closure[i][i] = true
-	}
-	for i, s := range n.states {
-		for _, t := range s.transfers {
-			if t.isEmpty {
-				h.closure[i][h.nfaStateId[t.target]] = true
-			}
-		}
-	}
-	for k := 0; k < h.size; k++ {
-		for i := 0; i < h.size; i++ {
-			for j := 0; j < h.size; j++ {
-				h.closure[i][j] = h.closure[i][j] || h.closure[i][k] && h.closure[k][j]
+		stack := []int{i}
+		for len(stack) > 0 {
+			x := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, t := range n.states[x].transfers {
+				if y := h.nfaStateId[t.target]; t.isEmpty && !h.closure[i][y] {
+					h.closure[i][y] = true
+					stack = append(stack, y)
+				}
 			}
 		}
 	}
@@ -231,17 +304,17 @@ func mergeNext(l1, u1 []rune, t1 [][]int, l2, u2 []rune, t2 [][]int) ([]rune, []
 				l2[j] = l1[i]
 			} else {
 				if u1[i] < u2[j] {
-					addChoice(l1[i], u1[i], append(t1[i], t2[j]...))
+					addChoice(l1[i], u1[i], append(copySlice(t1[i]), t2[j]...))
 					todo = append(todo, rec{l2, j, l2[j]})
 					l2[j] = u1[i] + 1
 					i++
 				} else if u2[j] < u1[i] {
-					addChoice(l2[j], u2[j], append(t2[j], t1[i]...))
+					addChoice(l2[j], u2[j], append(copySlice(t2[j]), t1[i]...))
 					todo = append(todo, rec{l1, i, l1[i]})
 					l1[i] = u2[j] + 1
 					j++
 				} else {
-					addChoice(l1[i], u1[i], append(t1[i], t2[j]...))
+					addChoice(l1[i], u1[i], append(copySlice(t1[i]), t2[j]...))
 					i++
 					j++
 				}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// goldenPatterns are patterns whose DFAs are recorded in testdata/dfa.golden.
+var goldenPatterns = []string{
+	"abc",
+	"(a|b)*a(a|b)(a|b)",
+	"[a-z]*(foo|fob|[f-h]x)+",
+	"(ab|a)(bc|c)*d?",
+	"~(.*ab.*)&[a-c]+",
+	"[^\\n]+\\n",
+	"(0|[1-9][0-9]*)(\\.[0-9]+)?",
+}
+
+// dumpDFAs returns the DFAs of goldenPatterns as text and in DOT.
+func dumpDFAs() string {
+	var sb strings.Builder
+	for _, p := range goldenPatterns {
+		d := constructDFA(constructNFA(p))
+		fmt.Fprintf(&sb, "pattern %q\n%s%s\n", p, convertDFAToString(d), convertDFAToDot(d))
+	}
+	return sb.String()
+}
+
+func TestDFAGolden(t *testing.T) {
+	const path = "testdata/dfa.golden"
+	got := dumpDFAs()
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("DFAs differ from %s, run go test -update if the change is intended", path)
+	}
+
+	// map iteration order is randomized, so repeat to catch dependence on it
+	for i := 0; i < 50; i++ {
+		if dumpDFAs() != got {
+			t.Fatal("DFAs differ between runs")
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// NodeKind is the kind of a node in the syntax tree.
+type NodeKind int
+
+const (
+	NodeLiteral    NodeKind = iota // a single rune, like a or \.
+	NodeAny                        // wildcard .
+	NodeCharClass                  // (negative) character class, like [a-z] or [^a-z]
+	NodeConcat                     // concatenation of Subs
+	NodeAlternate                  // alternation of Subs, separated by |
+	NodeIntersect                  // intersection of Subs, separated by &
+	NodeComplement                 // complement of Subs[0], written as ~
+	NodeRepeat                     // repetition of Subs[0], written as *, + or ?
+	NodeGroup                      // Subs[0] in parentheses
+	NodeEmpty                      // the empty string, which has no rek syntax
+)
+
+// String returns the name of the kind.
+func (k NodeKind) String() string {
+	names := []string{"Literal", "Any", "CharClass", "Concat", "Alternate",
+		"Intersect", "Complement", "Repeat", "Group", "Empty"}
+	if k < 0 || int(k) >= len(names) {
+		return fmt.Sprintf("NodeKind(%d)", int(k))
+	}
+	return names[k]
+}
+
+// Span is a range of byte offsets in the pattern, from Start (inclusive) to
+// End (exclusive).
+type Span struct {
+	Start, End int
+}
+
+// Node is a node in the syntax tree of a pattern.
+type Node struct {
+	Kind NodeKind
+	Span Span
+	// Rune is the rune matched by a NodeLiteral.
+	Rune rune
+	// Negated reports whether a NodeCharClass is written as [^...].
+	Negated bool
+	// Ranges are the ranges of a NodeCharClass as written, where each range
+	// is a pair of its lower and upper bound.
+	Ranges [][2]rune
+	// Min and Max are the bounds of a NodeRepeat, where Max is -1 if there is
+	// no upper bound. They are 0 and -1 for *, 1 and -1 for +, 0 and 1 for ?.
+	// Other bounds have no rek syntax, and are written by repeating Subs[0].
+	Min, Max int
+	// Subs are the children of the node.
+	Subs []*Node
+}
+
+// precedence of nodes, from the loosest to the tightest
+const (
+	precAlternate = iota
+	precIntersect
+	precConcat
+	precComplement
+	precRepeat
+	precAtom
+)
+
+// precedence returns the precedence of the node.
+func (n *Node) precedence() int {
+	switch n.Kind {
+	case NodeAlternate:
+		return precAlternate
+	case NodeIntersect:
+		return precIntersect
+	case NodeConcat:
+		return precConcat
+	case NodeComplement, NodeEmpty:
+		return precComplement
+	case NodeRepeat:
+		if n.isSimpleRepeat() {
+			return precRepeat
+		}
+		return precConcat
+	}
+	return precAtom
+}
+
+// String returns the node in canonical rek syntax. Character classes are
+// sorted and merged, escapes are only used where necessary, and parentheses
+// are added where needed by precedence.
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.write(&sb, precAlternate)
+	return sb.String()
+}
+
+// write writes the node in rek syntax, in parentheses if its precedence is
+// lower than prec.
+func (n *Node) write(sb *strings.Builder, prec int) {
+	if n.precedence() < prec {
+		sb.WriteByte('(')
+		defer sb.WriteByte(')')
+	}
+
+	switch n.Kind {
+	case NodeLiteral:
+		sb.WriteString(escapeRune(n.Rune))
+	case NodeAny:
+		sb.WriteByte('.')
+	case NodeCharClass:
+		lower, upper := n.area(false)
+		if len(lower) == 0 {
+			// an empty class has no rek syntax
+			if n.Negated {
+				sb.WriteString("(.|\\n)")
+			} else {
+				sb.WriteString("[^")
+				writeClassArea(sb, []rune{0}, []rune{utf8.MaxRune})
+				sb.WriteByte(']')
+			}
+			break
+		}
+		sb.WriteByte('[')
+		if n.Negated {
+			sb.WriteByte('^')
+		}
+		writeClassArea(sb, lower, upper)
+		sb.WriteByte(']')
+	case NodeConcat:
+		for _, s := range n.Subs {
+			s.write(sb, precConcat)
+		}
+	case NodeAlternate, NodeIntersect:
+		sep, subPrec := byte('|'), precAlternate
+		if n.Kind == NodeIntersect {
+			sep, subPrec = '&', precIntersect
+		}
+		for i, s := range n.Subs {
+			if i > 0 {
+				sb.WriteByte(sep)
+			}
+			s.write(sb, subPrec)
+		}
+	case NodeComplement:
+		sb.WriteByte('~')
+		n.Subs[0].write(sb, precComplement)
+	case NodeRepeat:
+		switch {
+		case n.Min == 0 && n.Max == -1:
+			n.Subs[0].write(sb, precAtom)
+			sb.WriteByte('*')
+		case n.Min == 1 && n.Max == -1:
+			n.Subs[0].write(sb, precAtom)
+			sb.WriteByte('+')
+		case n.Min == 0 && n.Max == 1:
+			n.Subs[0].write(sb, precAtom)
+			sb.WriteByte('?')
+		case n.Min == 0 && n.Max == 0:
+			(&Node{Kind: NodeEmpty}).write(sb, precConcat)
+		default:
+			// x{m,n} is written as m copies of x followed by n-m nested
+			// optional copies of x, such as xx(xx?)?
+			for i := 0; i < n.Min; i++ {
+				if i == n.Min-1 && n.Max == -1 {
+					n.Subs[0].write(sb, precAtom)
+					sb.WriteByte('+')
+				} else {
+					n.Subs[0].write(sb, precConcat)
+				}
+			}
+			if n.Max > n.Min {
+				for i := n.Min + 1; i < n.Max; i++ {
+					sb.WriteByte('(')
+					n.Subs[0].write(sb, precConcat)
+				}
+				n.Subs[0].write(sb, precAtom)
+				sb.WriteByte('?')
+				for i := n.Min + 1; i < n.Max; i++ {
+					sb.WriteString(")?")
+				}
+			}
+		}
+	case NodeGroup:
+		sb.WriteByte('(')
+		n.Subs[0].write(sb, precAlternate)
+		sb.WriteByte(')')
+	case NodeEmpty:
+		// the complement of all non-empty strings
+		sb.WriteString("~(.|\\n)+")
+	}
+}
+
+// isSimpleRepeat reports whether a NodeRepeat is written as *, + or ?.
+func (n *Node) isSimpleRepeat() bool {
+	return n.Max == -1 && (n.Min == 0 || n.Min == 1) || n.Min == 0 && n.Max == 1
+}
+
+// area returns the sorted and merged ranges of a NodeCharClass, inverted if
+// neg is true.
+func (n *Node) area(neg bool) ([]rune, []rune) {
+	area := make([][]rune, len(n.Ranges))
+	for i, r := range n.Ranges {
+		area[i] = []rune{r[0], r[1]}
+	}
+	return sortCharacterClass(neg, area)
+}
+
+// SyntaxError is an error in the syntax of a pattern.
+type SyntaxError struct {
+	Msg    string
+	Offset int // byte offset in the pattern
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
+// catchSyntaxError recovers a panicking *SyntaxError into err. It must be
+// called by defer.
+func catchSyntaxError(err *error) {
+	if x := recover(); x != nil {
+		e, ok := x.(*SyntaxError)
+		if !ok {
+			panic(x)
+		}
+		*err = e
+	}
+}
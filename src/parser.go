@@ -0,0 +1,329 @@
+package main
+
+// Parse parses a pattern in rek syntax into a syntax tree.
+func Parse(re string) (node *Node, err error) {
+	defer catchSyntaxError(&err)
+	return parse(re), nil
+}
+
+// kinds of marks in the stack of parseHelper
+const (
+	markParenthesis NodeKind = -1 - iota
+	markAlternate
+	markIntersect
+	markComplement
+)
+
+// parseHelper helps parse regular expression.
+type parseHelper struct {
+	offset []int // byte offset of every rune, followed by length of the pattern
+	stack  []*Node
+}
+
+// newParseHelper initializes a parseHelper.
+func newParseHelper(regexp string) *parseHelper {
+	h := &parseHelper{}
+	for i := range regexp {
+		h.offset = append(h.offset, i)
+	}
+	h.offset = append(h.offset, len(regexp))
+	return h
+}
+
+// fail panics with a syntax error at the i-th rune.
+func (h *parseHelper) fail(msg string, i int) {
+	panic(&SyntaxError{msg, h.offset[i]})
+}
+
+// isMark reports whether the node is a mark rather than a real node.
+func (h *parseHelper) isMark(n *Node) bool {
+	return n.Kind < 0
+}
+
+// peek returns the last node in the stack.
+func (h *parseHelper) peek() *Node {
+	if len(h.stack) == 0 {
+		return nil
+	}
+	return h.stack[len(h.stack)-1]
+}
+
+// pop returns the last node in the stack and removes it from the stack.
+func (h *parseHelper) pop() *Node {
+	t := h.peek()
+	if t != nil {
+		h.stack = h.stack[:len(h.stack)-1]
+	}
+	return t
+}
+
+// push pushes a node of runes from i to j (exclusive) into stack.
+func (h *parseHelper) push(n *Node, i, j int) {
+	n.Span = Span{h.offset[i], h.offset[j]}
+	h.stack = append(h.stack, n)
+}
+
+// repeat repeats the last node in the stack for min to max (-1 if unbounded)
+// times, where the repeat operator ends before the j-th rune.
+func (h *parseHelper) repeat(min, max, j int) {
+	n := &Node{Kind: NodeRepeat, Min: min, Max: max, Subs: []*Node{h.pop()}}
+	n.Span = Span{n.Subs[0].Span.Start, h.offset[j]}
+	h.stack = append(h.stack, n)
+}
+
+// group pops nodes from stack until there is a parenthesis mark, or the stack
+// is empty, and connects these nodes into a single one. If i is not -1, the
+// i-th rune is the closing parenthesis and the result is a group.
+func (h *parseHelper) group(i int) {
+	// intersect and concatenate all nodes after alternative mark (if exists)
+	var alters []*Node
+	var mark *Node
+	for {
+		last := len(h.stack) - 1
+		for last >= 0 && h.stack[last].Kind != markParenthesis && h.stack[last].Kind != markAlternate {
+			last--
+		}
+
+		if last == len(h.stack)-1 {
+			if last == -1 || h.stack[last].Kind == markParenthesis {
+				if i == -1 {
+					i = len(h.offset) - 1
+				}
+				h.fail("empty group", i)
+			}
+			panic(&SyntaxError{"empty alternative", h.stack[last].Span.Start})
+		}
+
+		alters = append(alters, h.intersect(h.stack[last+1:]))
+		h.stack = h.stack[:last+1]
+
+		if mark = h.pop(); mark == nil || mark.Kind == markParenthesis {
+			break
+		}
+	}
+
+	// merge all alternatives after the last parenthesis mark
+	n := alters[len(alters)-1]
+	if len(alters) > 1 {
+		n = &Node{Kind: NodeAlternate}
+		for j := len(alters) - 1; j >= 0; j-- {
+			n.Subs = append(n.Subs, alters[j])
+		}
+		n.Span = Span{n.Subs[0].Span.Start, alters[0].Span.End}
+	}
+	if i != -1 {
+		n = &Node{Kind: NodeGroup, Span: Span{mark.Span.Start, h.offset[i+1]}, Subs: []*Node{n}}
+	}
+	h.stack = append(h.stack, n)
+}
+
+// intersect splits nodes by intersection marks, concatenates nodes in each
+// part and intersects the results.
+func (h *parseHelper) intersect(items []*Node) *Node {
+	var parts []*Node
+	for {
+		i := 0
+		for i < len(items) && items[i].Kind != markIntersect {
+			i++
+		}
+		if i == 0 {
+			panic(&SyntaxError{"empty intersection", items[0].Span.Start})
+		}
+		parts = append(parts, h.concatenate(items[:i]))
+		if i == len(items) {
+			break
+		}
+		if i == len(items)-1 {
+			panic(&SyntaxError{"empty intersection", items[i].Span.End})
+		}
+		items = items[i+1:]
+	}
+
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return &Node{
+		Kind: NodeIntersect,
+		Span: Span{parts[0].Span.Start, parts[len(parts)-1].Span.End},
+		Subs: parts,
+	}
+}
+
+// concatenate applies complement marks and connects nodes together in series.
+func (h *parseHelper) concatenate(items []*Node) *Node {
+	var subs []*Node
+	for i := 0; i < len(items); i++ {
+		var marks []*Node
+		for items[i].Kind == markComplement {
+			if i == len(items)-1 {
+				panic(&SyntaxError{"invalid complement", items[i].Span.Start})
+			}
+			marks = append(marks, items[i])
+			i++
+		}
+		n := items[i]
+		for j := len(marks) - 1; j >= 0; j-- {
+			n = &Node{Kind: NodeComplement, Span: Span{marks[j].Span.Start, n.Span.End}, Subs: []*Node{n}}
+		}
+		subs = append(subs, n)
+	}
+
+	if len(subs) == 1 {
+		return subs[0]
+	}
+	return &Node{
+		Kind: NodeConcat,
+		Span: Span{subs[0].Span.Start, subs[len(subs)-1].Span.End},
+		Subs: subs,
+	}
+}
+
+// parse receives regular expression and outputs syntax tree.
+func parse(regexp string) *Node {
+	var parCnt int
+	re := []rune(regexp)
+	p := newParseHelper(regexp)
+
+	for i := 0; i < len(re); i++ {
+		switch re[i] {
+		case '(':
+			parCnt++
+			p.push(&Node{Kind: markParenthesis}, i, i+1)
+		case ')':
+			if p.peek() == nil || p.isMark(p.peek()) {
+				p.fail("invalid parenthesis", i)
+			}
+			if parCnt == 0 {
+				p.fail("mismatched parentheses", i)
+			}
+			parCnt--
+			p.group(i)
+		case '*', '+', '?':
+			if p.peek() == nil || p.isMark(p.peek()) || p.peek().Kind == NodeRepeat {
+				p.fail("invalid repeat", i)
+			}
+			switch re[i] {
+			case '*':
+				p.repeat(0, -1, i+1)
+			case '+':
+				p.repeat(1, -1, i+1)
+			case '?':
+				p.repeat(0, 1, i+1)
+			}
+		case '|':
+			if p.peek() == nil || p.isMark(p.peek()) {
+				p.fail("invalid alternative", i)
+			}
+			p.push(&Node{Kind: markAlternate}, i, i+1)
+		case '&':
+			if p.peek() == nil || p.isMark(p.peek()) {
+				p.fail("invalid intersection", i)
+			}
+			p.push(&Node{Kind: markIntersect}, i, i+1)
+		case '~':
+			p.push(&Node{Kind: markComplement}, i, i+1)
+		case '.':
+			p.push(&Node{Kind: NodeAny}, i, i+1)
+		case '[':
+			start := i
+			i++
+			// negative flag
+			neg := false
+			if i < len(re) && re[i] == '^' {
+				neg = true
+				i++
+			}
+
+			// read a character from regular expression
+			getChar := func() (result rune) {
+				if i == len(re) {
+					p.fail("missing closing bracket", start)
+				}
+				if re[i] == '\\' {
+					i++
+					if i == len(re) {
+						p.fail("missing closing bracket", start)
+					}
+					if re[i] == '^' || re[i] == '-' {
+						result = re[i]
+					} else if r, ok := decodeEscapable(re[i]); ok {
+						result = r
+					} else {
+						p.fail("inescapable character", i)
+					}
+				} else {
+					result = re[i]
+				}
+				i++
+				return result
+			}
+
+			// collect characters in the class
+			var ranges [][2]rune
+			for {
+				if i == len(re) {
+					p.fail("missing closing bracket", start)
+				}
+				if re[i] == ']' {
+					break
+				}
+				j := i
+				ch1 := getChar()
+				if i < len(re) && re[i] == '-' {
+					i++
+					ch2 := getChar()
+					if ch1 > ch2 {
+						p.fail("illegal range", j)
+					}
+					ranges = append(ranges, [2]rune{ch1, ch2})
+				} else {
+					ranges = append(ranges, [2]rune{ch1, ch1})
+				}
+			}
+			if len(ranges) == 0 {
+				p.fail("empty character class", start)
+			}
+			p.push(&Node{Kind: NodeCharClass, Negated: neg, Ranges: ranges}, start, i+1)
+		case '\\':
+			i++
+			if i == len(re) {
+				p.fail("trailing backslash", i-1)
+			}
+			r, ok := decodeEscapable(re[i])
+			if !ok {
+				p.fail("inescapable character", i)
+			}
+			p.push(&Node{Kind: NodeLiteral, Rune: r}, i-1, i+1)
+		default:
+			p.push(&Node{Kind: NodeLiteral, Rune: re[i]}, i, i+1)
+		}
+	}
+
+	return p.finish(parCnt)
+}
+
+// finish checks that all parentheses are closed, where parCnt is the number
+// of open parentheses, and returns the syntax tree of the whole pattern.
+func (h *parseHelper) finish(parCnt int) *Node {
+	if parCnt != 0 {
+		for i := len(h.stack) - 1; ; i-- {
+			if h.stack[i].Kind == markParenthesis {
+				panic(&SyntaxError{"mismatched parentheses", h.stack[i].Span.Start})
+			}
+		}
+	}
+	h.group(-1)
+	return h.pop()
+}
+
+// decodeEscapable returns real value of escaped character, and false if the
+// character is not escapable.
+func decodeEscapable(r rune) (rune, bool) {
+	escape := map[rune]rune{
+		'\\': '\\', '(': '(', ')': ')', '*': '*', '+': '+', '?': '?',
+		'|': '|', '.': '.', '[': '[', ']': ']', '&': '&', '~': '~',
+		't': '\t', 'r': '\r', 'n': '\n',
+	}
+	v, ok := escape[r]
+	return v, ok
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		re, canonical string
+	}{
+		{"(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)", "(a*|b*)[0-9]?[A-Za-z]+(x?y?z?|abc)"},
+		{"\\.(a?b|(xy)+|yz*).\\t", "\\.(a?b|(xy)+|yz*).\\t"},
+		{"[\\.z-za]|\\]", "[.az]|\\]"},
+		{"[^\\^\\-]", "[^\\-\\^]"},
+		{"~(.*secret.*)&[a-z]+", "~(.*secret.*)&[a-z]+"},
+		{"~~a*b&c|d", "~~a*b&c|d"},
+		{"a]", "a\\]"},
+	}
+	for _, c := range cases {
+		n, err := Parse(c.re)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.re, err)
+			continue
+		}
+		if s := n.String(); s != c.canonical {
+			t.Errorf("Parse(%q).String() = %q, want %q", c.re, s, c.canonical)
+		}
+		if n.Span != (Span{0, len(c.re)}) {
+			t.Errorf("Parse(%q).Span = %v", c.re, n.Span)
+		}
+	}
+
+	// operator precedence and spans
+	n, _ := Parse("~a*b&c|dé")
+	if n.Kind != NodeAlternate || n.Subs[0].Kind != NodeIntersect ||
+		n.Subs[0].Subs[0].Kind != NodeConcat || n.Subs[0].Subs[0].Subs[0].Kind != NodeComplement ||
+		n.Subs[0].Subs[0].Subs[0].Subs[0].Kind != NodeRepeat {
+		t.Errorf("unexpected syntax tree of %q", "~a*b&c|dé")
+	}
+	if s := n.Subs[1].Span; s != (Span{7, 10}) {
+		t.Errorf("span of %q = %v", "dé", s)
+	}
+
+	// syntax tree built by hand gets parentheses where needed
+	lit := func(r rune) *Node { return &Node{Kind: NodeLiteral, Rune: r} }
+	n = &Node{Kind: NodeConcat, Subs: []*Node{
+		{Kind: NodeAlternate, Subs: []*Node{lit('a'), lit('b')}},
+		{Kind: NodeRepeat, Min: 0, Max: -1, Subs: []*Node{
+			{Kind: NodeComplement, Subs: []*Node{lit('c')}},
+		}},
+	}}
+	if s := n.String(); s != "(a|b)(~c)*" {
+		t.Errorf("String() = %q", s)
+	}
+
+	errors := []struct {
+		re     string
+		offset int
+	}{
+		{"", 0},
+		{"a(", 1},
+		{"a)", 1},
+		{"a**", 2},
+		{"a|", 1},
+		{"a[b", 1},
+		{"[a", 0},
+		{"[z-a]", 1},
+		{"a\\", 1},
+		{"é\\x", 3},
+		{"a&", 2},
+		{"a~", 1},
+	}
+	for _, c := range errors {
+		_, err := Parse(c.re)
+		if e, ok := err.(*SyntaxError); !ok || e.Offset != c.offset {
+			t.Errorf("Parse(%q) = %v, want error at offset %d", c.re, err, c.offset)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maximum bound of intervals like {m,n}, as RE_DUP_MAX in POSIX
+const posixDupMax = 255
+
+// posixClasses are the character classes allowed in bracket expressions, like
+// [[:alpha:]], restricted to ASCII.
+var posixClasses = map[string][][2]rune{
+	"alpha":  {{'A', 'Z'}, {'a', 'z'}},
+	"digit":  {{'0', '9'}},
+	"alnum":  {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}},
+	"upper":  {{'A', 'Z'}},
+	"lower":  {{'a', 'z'}},
+	"space":  {{'\t', '\r'}, {' ', ' '}},
+	"blank":  {{'\t', '\t'}, {' ', ' '}},
+	"punct":  {{'!', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}},
+	"print":  {{' ', '~'}},
+	"graph":  {{'!', '~'}},
+	"cntrl":  {{0, 0x1f}, {0x7f, 0x7f}},
+	"xdigit": {{'0', '9'}, {'A', 'F'}, {'a', 'f'}},
+}
+
+// parsePOSIX receives regular expression in POSIX extended syntax, or basic
+// syntax if basic is true, and outputs syntax tree. As the whole input is
+// always matched, ^ and $ are only anchors at the beginning and the end of the
+// pattern.
+func parsePOSIX(regexp string, basic bool) *Node {
+	var parCnt int
+	re := []rune(regexp)
+	p := newParseHelper(regexp)
+
+	// escapable characters, and operators written without backslash
+	escapable, operators := "\\|()*+?{}.[]^$", "()|*+?{.[^$"
+	if basic {
+		escapable, operators = "\\(){}*.[]^$", "*.[^$"
+	}
+
+	for i := 0; i < len(re); i++ {
+		start := i
+		// op is the operator at i, or 0 if it's a literal
+		var op rune
+		if re[i] == '\\' {
+			i++
+			if i == len(re) {
+				p.fail("trailing backslash", start)
+			}
+			if !strings.ContainsRune(escapable, re[i]) {
+				p.fail("inescapable character", i)
+			}
+			if basic && strings.ContainsRune("(){}", re[i]) {
+				op = re[i]
+			}
+		} else if strings.ContainsRune(operators, re[i]) {
+			op = re[i]
+		}
+		// nothing to repeat if the stack is empty or ends with a mark
+		noOperand := p.peek() == nil || p.isMark(p.peek())
+
+		switch op {
+		case 0:
+			p.push(&Node{Kind: NodeLiteral, Rune: re[i]}, start, i+1)
+		case '(':
+			parCnt++
+			p.push(&Node{Kind: markParenthesis}, start, i+1)
+		case ')':
+			if parCnt == 0 {
+				if basic {
+					p.fail("mismatched parentheses", start)
+				}
+				// an unmatched ) is a literal in ERE
+				p.push(&Node{Kind: NodeLiteral, Rune: ')'}, start, i+1)
+				break
+			}
+			parCnt--
+			p.group(i)
+		case '|':
+			if noOperand {
+				p.fail("invalid alternative", i)
+			}
+			p.push(&Node{Kind: markAlternate}, i, i+1)
+		case '*', '+', '?':
+			if noOperand {
+				if basic {
+					// a leading * is a literal in BRE
+					p.push(&Node{Kind: NodeLiteral, Rune: re[i]}, start, i+1)
+					break
+				}
+				p.fail("invalid repeat", i)
+			}
+			switch op {
+			case '*':
+				p.repeat(0, -1, i+1)
+			case '+':
+				p.repeat(1, -1, i+1)
+			case '?':
+				p.repeat(0, 1, i+1)
+			}
+		case '{':
+			if !basic && (i+1 == len(re) || re[i+1] < '0' || re[i+1] > '9') {
+				// { not starting an interval is a literal in ERE
+				p.push(&Node{Kind: NodeLiteral, Rune: '{'}, start, i+1)
+				break
+			}
+			if noOperand {
+				p.fail("invalid repeat", start)
+			}
+			var min, max int
+			min, max, i = p.parseInterval(re, i+1, basic)
+			p.repeat(min, max, i+1)
+		case '}':
+			p.fail("invalid repeat", start)
+		case '.':
+			p.push(&Node{Kind: NodeCharClass, Ranges: [][2]rune{{0, utf8.MaxRune}}}, i, i+1)
+		case '^', '$':
+			if op == '^' && i == 0 || op == '$' && i == len(re)-1 {
+				// anchors at the edges always hold
+				break
+			}
+			if !basic {
+				p.fail("unsupported anchor", i)
+			}
+			p.push(&Node{Kind: NodeLiteral, Rune: op}, i, i+1)
+		case '[':
+			var n *Node
+			n, i = p.parseBracket(re, i)
+			p.push(n, start, i+1)
+		}
+	}
+
+	return p.finish(parCnt)
+}
+
+// parseInterval parses the bounds of an interval starting at the i-th rune,
+// and returns the bounds (max is -1 if unbounded) and the index of the closing
+// brace.
+func (h *parseHelper) parseInterval(re []rune, i int, basic bool) (int, int, int) {
+	start := i
+	readNumber := func() int {
+		if i == len(re) || re[i] < '0' || re[i] > '9' {
+			h.fail("invalid repeat count", start)
+		}
+		var v int
+		for ; i < len(re) && '0' <= re[i] && re[i] <= '9'; i++ {
+			v = v*10 + int(re[i]-'0')
+			if v > posixDupMax {
+				h.fail("invalid repeat count", start)
+			}
+		}
+		return v
+	}
+
+	min := readNumber()
+	max := min
+	if i < len(re) && re[i] == ',' {
+		i++
+		if i < len(re) && '0' <= re[i] && re[i] <= '9' {
+			max = readNumber()
+		} else {
+			max = -1
+		}
+	}
+	if basic {
+		if i+1 >= len(re) || re[i] != '\\' || re[i+1] != '}' {
+			h.fail("invalid repeat count", start)
+		}
+		i++
+	} else if i == len(re) || re[i] != '}' {
+		h.fail("invalid repeat count", start)
+	}
+	if max != -1 && min > max {
+		h.fail("invalid repeat count", start)
+	}
+	return min, max, i
+}
+
+// parseBracket parses a bracket expression starting at the i-th rune, and
+// returns the character class and the index of the closing bracket. A ] at the
+// beginning and a - at the beginning or the end are literals, and backslashes
+// have no special meaning.
+func (h *parseHelper) parseBracket(re []rune, i int) (*Node, int) {
+	start := i
+	i++
+	n := &Node{Kind: NodeCharClass}
+	if i < len(re) && re[i] == '^' {
+		n.Negated = true
+		i++
+	}
+
+	// read an element, which is a single character, or a character class
+	// whose ranges are returned in ranges
+	getElement := func() (ch rune, ranges [][2]rune) {
+		if i+1 < len(re) && re[i] == '[' && strings.ContainsRune(":.=", re[i+1]) {
+			delim, j := re[i+1], i+2
+			for j+1 < len(re) && (re[j] != delim || re[j+1] != ']') {
+				j++
+			}
+			if j+1 >= len(re) {
+				h.fail("missing closing bracket", start)
+			}
+			name := string(re[i+2 : j])
+			if delim == ':' {
+				ranges, ok := posixClasses[name]
+				if !ok {
+					h.fail("unknown character class", i)
+				}
+				i = j + 2
+				return 0, ranges
+			}
+			// only single characters are supported as collating elements
+			if utf8.RuneCountInString(name) != 1 {
+				h.fail("unsupported collating element", i)
+			}
+			i = j + 2
+			return re[j-1], nil
+		}
+		i++
+		return re[i-1], nil
+	}
+
+	for first := true; ; first = false {
+		if i == len(re) {
+			h.fail("missing closing bracket", start)
+		}
+		if re[i] == ']' && !first {
+			break
+		}
+		j := i
+		ch1, ranges := getElement()
+		if ranges != nil {
+			n.Ranges = append(n.Ranges, ranges...)
+			continue
+		}
+		if i+1 < len(re) && re[i] == '-' && re[i+1] != ']' {
+			i++
+			ch2, ranges := getElement()
+			if ranges != nil || ch1 > ch2 {
+				h.fail("illegal range", j)
+			}
+			n.Ranges = append(n.Ranges, [2]rune{ch1, ch2})
+		} else {
+			n.Ranges = append(n.Ranges, [2]rune{ch1, ch1})
+		}
+	}
+	return n, i
+}
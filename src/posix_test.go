@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestCompileWithOptions(t *testing.T) {
+	cases := []struct {
+		syntax   Syntax
+		re       string
+		accepted []string
+		rejected []string
+	}{
+		{SyntaxERE, "^(ab|c)+d?$", []string{"ab", "cabd", "ccc"}, []string{"", "abab d", "^ab$"}},
+		{SyntaxERE, "a{2,3}b{2}c{1,}", []string{"aabbc", "aaabbccc"}, []string{"abbc", "aaaabbc", "aabbbc", "aabb"}},
+		{SyntaxERE, "x{|y}|\\.\\{", []string{"x{", "y}", ".{"}, []string{"x", "a{"}},
+		{SyntaxERE, "ba**|b)", []string{"b", "baa", "b)"}, []string{"a", ")"}},
+		{SyntaxERE, "a.c", []string{"abc", "a\nc"}, []string{"ac"}},
+		{SyntaxERE, "[]a-]+", []string{"]", "a-]"}, []string{"b"}},
+		{SyntaxERE, "[^]x]", []string{"a", "\\"}, []string{"]", "x"}},
+		{SyntaxERE, "[[:alpha:][:digit:]_]+", []string{"abc_123", "Z"}, []string{"a-b", "é"}},
+		{SyntaxERE, "[[.-.]a[=b=]\\]+", []string{"-ab\\"}, []string{"c", "."}},
+		{SyntaxERE, "[--/]", []string{"-", ".", "/"}, []string{"0"}},
+		{SyntaxBRE, "^\\(ab\\)*c\\{2,3\\}$", []string{"cc", "ababccc"}, []string{"abc", "cccc"}},
+		{SyntaxBRE, "a+b?(c|d){1}", []string{"a+b?(c|d){1}"}, []string{"ab", "aac"}},
+		{SyntaxBRE, "*a\\(*b\\)", []string{"*a*b"}, []string{"ab", "a"}},
+		{SyntaxBRE, "a^b$c\\.", []string{"a^b$c."}, []string{"abc."}},
+		{SyntaxBRE, "\\(x\\{2\\}\\)\\{1,\\}y", []string{"xxy", "xxxxy"}, []string{"xy", "xxxy"}},
+		{SyntaxRek, "~(.*a)&[ab]+", []string{"b", "ab"}, []string{"a", "ba"}},
+	}
+	for _, c := range cases {
+		r, err := CompileWithOptions(c.re, CompileOptions{Syntax: c.syntax})
+		if err != nil {
+			t.Errorf("CompileWithOptions(%q, %d): %v", c.re, c.syntax, err)
+			continue
+		}
+		for _, s := range c.accepted {
+			if !r.Match(s) {
+				t.Errorf("%q (syntax %d) rejects %q", c.re, c.syntax, s)
+			}
+		}
+		for _, s := range c.rejected {
+			if r.Match(s) {
+				t.Errorf("%q (syntax %d) accepts %q", c.re, c.syntax, s)
+			}
+		}
+	}
+
+	errors := []struct {
+		syntax Syntax
+		re     string
+		msg    string
+		offset int
+	}{
+		{SyntaxERE, "*a", "invalid repeat", 0},
+		{SyntaxERE, "a(+b)", "invalid repeat", 2},
+		{SyntaxERE, "a{2,1}", "invalid repeat count", 2},
+		{SyntaxERE, "a{256}", "invalid repeat count", 2},
+		{SyntaxERE, "a{2", "invalid repeat count", 2},
+		{SyntaxERE, "a^b", "unsupported anchor", 1},
+		{SyntaxERE, "a$b", "unsupported anchor", 1},
+		{SyntaxERE, "\\d", "inescapable character", 1},
+		{SyntaxERE, "[a", "missing closing bracket", 0},
+		{SyntaxERE, "[]", "missing closing bracket", 0},
+		{SyntaxERE, "[[:word:]]", "unknown character class", 1},
+		{SyntaxERE, "[[.ch.]]", "unsupported collating element", 1},
+		{SyntaxERE, "[z-a]", "illegal range", 1},
+		{SyntaxERE, "[a-[:digit:]]", "illegal range", 1},
+		{SyntaxERE, "(a", "mismatched parentheses", 0},
+		{SyntaxERE, "a||b", "invalid alternative", 2},
+		{SyntaxBRE, "a\\{1", "invalid repeat count", 3},
+		{SyntaxBRE, "a\\{1}", "invalid repeat count", 3},
+		{SyntaxBRE, "a\\)", "mismatched parentheses", 1},
+		{SyntaxBRE, "a\\}", "invalid repeat", 1},
+		{SyntaxBRE, "\\+", "inescapable character", 1},
+	}
+	for _, c := range errors {
+		_, err := CompileWithOptions(c.re, CompileOptions{Syntax: c.syntax})
+		e, ok := err.(*SyntaxError)
+		if !ok || e.Msg != c.msg || e.Offset != c.offset {
+			t.Errorf("CompileWithOptions(%q, %d) = %v, want %s at offset %d", c.re, c.syntax, err, c.msg, c.offset)
+		}
+	}
+
+	if _, err := CompileWithOptions("a*", CompileOptions{Syntax: SyntaxERE}); err == nil {
+		t.Error("CompileWithOptions accepts a pattern matching the empty string")
+	}
+}
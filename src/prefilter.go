@@ -0,0 +1,256 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// limits of literal prefixes used to find where matches may start
+const (
+	maxPrefixes   = 8
+	maxPrefixLen  = 16 // in runes
+	maxRequireLen = 64 // in bytes
+)
+
+// LiteralPrefix returns a literal string that must begin any match of re, like
+// LiteralPrefix of package regexp. It returns true if the literal string is the
+// only string matched by re.
+func (re *REK) LiteralPrefix() (prefix string, complete bool) {
+	d := re.d.literals().trimmed
+	var sb strings.Builder
+	state := 0
+	// a cycle without end states is possible if nothing is matched
+	for i := 0; i < len(d.states); i++ {
+		s := d.states[state]
+		if s.isEnd || len(s.transfers) != 1 || s.transfers[0].lower != s.transfers[0].upper {
+			break
+		}
+		sb.WriteRune(s.transfers[0].lower)
+		state = s.transfers[0].target
+	}
+	s := d.states[state]
+	return sb.String(), s.isEnd && len(s.transfers) == 0
+}
+
+// literalInfo is literals extracted from a DFA, which are used to skip parts
+// of inputs where matches can't start.
+type literalInfo struct {
+	// trimmed is the DFA without useless states
+	trimmed *dfa
+	// prefixes are literals every match starts with, or nil if there are too
+	// many of them or the empty string is one of them
+	prefixes []string
+	// firsts are the first runes of prefixes
+	firsts string
+}
+
+// literals returns the literals of d, which are extracted on the first call.
+func (d *dfa) literals() *literalInfo {
+	d.literalsOnce.Do(func() {
+		t := trimDFA(d)
+		d.literalInfo = &literalInfo{trimmed: t, prefixes: literalPrefixes(t)}
+		for _, p := range d.literalInfo.prefixes {
+			r, _ := utf8.DecodeRuneInString(p)
+			if !strings.ContainsRune(d.literalInfo.firsts, r) {
+				d.literalInfo.firsts += string(r)
+			}
+		}
+	})
+	return d.literalInfo
+}
+
+// literalPrefixes returns at most maxPrefixes literals which every match of the
+// trimmed DFA d starts with, or nil if there's no such set of literals.
+func literalPrefixes(d *dfa) []string {
+	type item struct {
+		prefix string
+		state  int
+		done   bool
+	}
+	items := []item{{"", 0, false}}
+	isVisited := make([]bool, len(d.states))
+	isVisited[0] = true
+	for n := 0; n < maxPrefixLen; n++ {
+		var next []item
+		var reached []int
+		for _, it := range items {
+			s := d.states[it.state]
+			if it.done || s.isEnd || !isSmall(s.transfers) || revisits(s.transfers, isVisited) {
+				// matches may end here or continue with too many runes, and
+				// following loops only gives longer prefixes of little use
+				next = append(next, item{it.prefix, it.state, true})
+				continue
+			}
+			for _, t := range s.transfers {
+				reached = append(reached, t.target)
+				for r := t.lower; r <= t.upper; r++ {
+					next = append(next, item{it.prefix + string(r), t.target, false})
+				}
+			}
+		}
+		if len(next) > maxPrefixes {
+			break
+		}
+		items = next
+		// states reached in the same round may be shared by prefixes
+		for _, state := range reached {
+			isVisited[state] = true
+		}
+	}
+
+	var prefixes []string
+	for _, it := range items {
+		// invalid UTF-8 is decoded as utf8.RuneError, which strings.Index
+		// can't find
+		if it.prefix == "" || strings.ContainsRune(it.prefix, utf8.RuneError) {
+			return nil
+		}
+		prefixes = append(prefixes, it.prefix)
+	}
+	return prefixes
+}
+
+// revisits reports whether any of transfers leads to a visited state.
+func revisits(transfers []dfaTransfer, isVisited []bool) bool {
+	for _, t := range transfers {
+		if isVisited[t.target] {
+			return true
+		}
+	}
+	return false
+}
+
+// isSmall reports whether transfers accept at most maxPrefixes runes.
+func isSmall(transfers []dfaTransfer) bool {
+	var n rune
+	for _, t := range transfers {
+		if n += t.upper - t.lower + 1; n > maxPrefixes {
+			return false
+		}
+	}
+	return true
+}
+
+// literalFacts are literals known to be in every string matched by a node.
+type literalFacts struct {
+	exact    bool   // whether exactly one string, which is prefix, is matched
+	prefix   string // every string matched starts with it
+	suffix   string // every string matched ends with it
+	required string // every string matched contains it
+}
+
+// requiredLiteral returns the longest literal found in the syntax tree which
+// every string matched by n contains, or "" if there is none.
+func requiredLiteral(n *Node) string {
+	return usableRequired(literalFactsOf(n).required)
+}
+
+// usableRequired returns r if it can be used as the required literal, or ""
+// if it's too long or contains utf8.RuneError, which invalid UTF-8 in inputs
+// is decoded as but strings.Contains can't find.
+func usableRequired(r string) string {
+	if len(r) > maxRequireLen || strings.ContainsRune(r, utf8.RuneError) {
+		return ""
+	}
+	return r
+}
+
+// literalFactsOf returns the literals found in the syntax tree of n.
+func literalFactsOf(n *Node) literalFacts {
+	switch n.Kind {
+	case NodeLiteral:
+		s := string(n.Rune)
+		return literalFacts{true, s, s, s}
+	case NodeCharClass:
+		if lower, upper := n.area(n.Negated); len(lower) == 1 && lower[0] == upper[0] {
+			s := string(lower[0])
+			return literalFacts{true, s, s, s}
+		}
+	case NodeEmpty:
+		return literalFacts{exact: true}
+	case NodeGroup:
+		return literalFactsOf(n.Subs[0])
+	case NodeConcat:
+		f := literalFacts{exact: true}
+		for _, sub := range n.Subs {
+			g := literalFactsOf(sub)
+			if f.exact {
+				f.prefix += g.prefix
+			}
+			if g.exact {
+				f.suffix += g.prefix
+			} else {
+				// the literal running across subs ends within sub
+				f.required = longer(f.required, f.suffix+g.prefix)
+				f.suffix = g.suffix
+			}
+			f.required = longer(f.required, longer(f.suffix, g.required))
+			f.exact = f.exact && g.exact
+		}
+		return f
+	case NodeRepeat:
+		if n.Min == 0 {
+			break
+		}
+		g := literalFactsOf(n.Subs[0])
+		if n.Min == 1 && n.Max == 1 {
+			return g
+		}
+		return literalFacts{false, g.prefix, g.suffix, g.required}
+	case NodeIntersect:
+		// a match of an intersection matches all of its subs
+		var f literalFacts
+		for _, sub := range n.Subs {
+			g := literalFactsOf(sub)
+			f.prefix = longer(f.prefix, g.prefix)
+			f.suffix = longer(f.suffix, g.suffix)
+			f.required = longer(f.required, g.required)
+		}
+		return f
+	}
+	return literalFacts{}
+}
+
+// longer returns the longer one of a and b, or a if they are equally long.
+func longer(a, b string) string {
+	if len(b) > len(a) {
+		return b
+	}
+	return a
+}
+
+// mayMatch reports whether s contains the required literal of d, which is
+// necessary for s to contain a match.
+func (d *dfa) mayMatch(s string) bool {
+	return d.required == "" || strings.Contains(s, d.required)
+}
+
+// nextCandidate returns the first byte offset from i where a match of d may
+// start, judging from its literal prefixes, or -1 if no match starts after i.
+func (d *dfa) nextCandidate(s string, i int) int {
+	info := d.literals()
+	switch len(info.prefixes) {
+	case 0:
+		return i
+	case 1:
+		if k := strings.Index(s[i:], info.prefixes[0]); k != -1 {
+			return i + k
+		}
+		return -1
+	}
+	for i < len(s) {
+		k := strings.IndexAny(s[i:], info.firsts)
+		if k == -1 {
+			return -1
+		}
+		i += k
+		for _, p := range info.prefixes {
+			if strings.HasPrefix(s[i:], p) {
+				return i
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return -1
+}
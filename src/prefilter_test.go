@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLiteralPrefix(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		prefix   string
+		complete bool
+	}{
+		{"abc", "abc", true},
+		{"abc[0-9]", "abc", false},
+		{"abc|abd", "ab", false},
+		{"ab(c)*", "ab", false},
+		{".*x", "", false},
+		{"é(ab)+", "éab", false},
+		{"~(.*)&a", "", false},
+		{"ab&(a|b)*", "ab", true},
+	}
+	for _, test := range tests {
+		r := Compile(test.pattern)
+		if prefix, complete := r.LiteralPrefix(); prefix != test.prefix || complete != test.complete {
+			t.Errorf("%q.LiteralPrefix() = %q, %v, want %q, %v", test.pattern, prefix, complete, test.prefix, test.complete)
+		}
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		required string
+		prefixes []string
+	}{
+		{".*ERROR [0-9]+", "ERROR ", nil},
+		{"(foo|bar)baz", "baz", []string{"barbaz", "foobaz"}},
+		{"a*bcd*", "bc", nil},
+		{"(ab)+cd", "abcd", []string{"ab"}},
+		{"~(a)b", "b", nil},
+		{"abc&(.*b.*)", "abc", []string{"abc"}},
+		{"[ab]x", "x", []string{"ax", "bx"}},
+		{"[0-9]+", "", nil},
+		{"\uFFFDb", "", nil},
+	}
+	for _, test := range tests {
+		r := Compile(test.pattern)
+		if r.d.required != test.required {
+			t.Errorf("required literal of %q is %q, want %q", test.pattern, r.d.required, test.required)
+		}
+		if got := r.d.literals().prefixes; fmt.Sprint(got) != fmt.Sprint(test.prefixes) {
+			t.Errorf("literal prefixes of %q are %q, want %q", test.pattern, got, test.prefixes)
+		}
+	}
+}
+
+func BenchmarkContainsLiteral(b *testing.B) {
+	// most lines are ruled out by the required literal, like in rek grep
+	r := Compile(".*ERROR [0-9]+")
+	lines := strings.Split(strings.Repeat("INFO 2024-01-01 request served in 12ms\n", 99)+"ERROR 500", "\n")
+	r.Contains(lines[0])
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			r.Contains(line)
+		}
+	}
+}
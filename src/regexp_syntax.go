@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompileSyntax compiles a parse tree of the standard regexp/syntax package,
+// so that patterns written for package regexp can be matched by rek. Like
+// Match, the parse tree is matched against the whole input, so ^ and $ (or \A
+// and \z) are only supported at the beginning and the end of the pattern, and
+// they must begin or end every alternative. When searching, as FindString
+// does, they anchor matches at the beginning or the end of the input like in
+// package regexp. Multi-line anchors, as in (?m)^, and word boundaries are not
+// supported. Captures are treated as plain groups, and non-greedy repeats as
+// greedy ones.
+//
+// Unlike Compile, patterns matching the empty string are accepted.
+func CompileSyntax(re *syntax.Regexp) (REK, error) {
+	n, err := convertSyntax(re, true, true)
+	if err != nil {
+		return REK{}, err
+	}
+	anchorStart, anchorEnd := isAnchored(re, syntax.OpBeginText), isAnchored(re, syntax.OpEndText)
+	if !anchorStart && hasOp(re, syntax.OpBeginText) {
+		return REK{}, fmt.Errorf("unsupported %v not beginning every match of %q", syntax.OpBeginText, re)
+	}
+	if !anchorEnd && hasOp(re, syntax.OpEndText) {
+		return REK{}, fmt.Errorf("unsupported %v not ending every match of %q", syntax.OpEndText, re)
+	}
+	d := noLimits.compileNode(n)
+	d.anchorStart, d.anchorEnd = anchorStart, anchorEnd
+	return REK{d}, nil
+}
+
+// isAnchored reports whether every match of re begins with op if it's
+// syntax.OpBeginText, or ends with op if it's syntax.OpEndText.
+func isAnchored(re *syntax.Regexp, op syntax.Op) bool {
+	switch re.Op {
+	case op:
+		return true
+	case syntax.OpCapture:
+		return isAnchored(re.Sub[0], op)
+	case syntax.OpConcat:
+		if len(re.Sub) == 0 {
+			return false
+		}
+		if op == syntax.OpBeginText {
+			return isAnchored(re.Sub[0], op)
+		}
+		return isAnchored(re.Sub[len(re.Sub)-1], op)
+	case syntax.OpAlternate:
+		for _, s := range re.Sub {
+			if !isAnchored(s, op) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpPlus, syntax.OpRepeat:
+		return re.Min >= 1 && isAnchored(re.Sub[0], op)
+	}
+	return false
+}
+
+// hasOp reports whether op is used anywhere in re.
+func hasOp(re *syntax.Regexp, op syntax.Op) bool {
+	if re.Op == op {
+		return true
+	}
+	for _, s := range re.Sub {
+		if hasOp(s, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertSyntax converts a parse tree of regexp/syntax to a syntax tree of rek.
+// atStart and atEnd report whether re is at the beginning or the end of the
+// whole pattern, where empty-width assertions always hold.
+func convertSyntax(re *syntax.Regexp, atStart, atEnd bool) (*Node, error) {
+	convertSubs := func(inherit bool) ([]*Node, error) {
+		subs := make([]*Node, len(re.Sub))
+		for i, s := range re.Sub {
+			var err error
+			if inherit {
+				subs[i], err = convertSyntax(s, atStart, atEnd)
+			} else {
+				subs[i], err = convertSyntax(s, atStart && i == 0, atEnd && i == len(re.Sub)-1)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return subs, nil
+	}
+
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return &Node{Kind: NodeCharClass}, nil
+	case syntax.OpEmptyMatch:
+		return &Node{Kind: NodeEmpty}, nil
+	case syntax.OpLiteral:
+		n := &Node{Kind: NodeConcat}
+		for _, r := range re.Rune {
+			n.Subs = append(n.Subs, foldLiteral(r, re.Flags&syntax.FoldCase != 0))
+		}
+		if len(n.Subs) == 1 {
+			return n.Subs[0], nil
+		}
+		return n, nil
+	case syntax.OpCharClass:
+		n := &Node{Kind: NodeCharClass}
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			n.Ranges = append(n.Ranges, [2]rune{re.Rune[i], re.Rune[i+1]})
+		}
+		return n, nil
+	case syntax.OpAnyCharNotNL:
+		return &Node{Kind: NodeAny}, nil
+	case syntax.OpAnyChar:
+		return &Node{Kind: NodeCharClass, Ranges: [][2]rune{{0, utf8.MaxRune}}}, nil
+	case syntax.OpBeginText:
+		if !atStart {
+			return nil, fmt.Errorf("unsupported %v in the middle of %q", re.Op, re)
+		}
+		return &Node{Kind: NodeEmpty}, nil
+	case syntax.OpEndText:
+		if !atEnd {
+			return nil, fmt.Errorf("unsupported %v in the middle of %q", re.Op, re)
+		}
+		return &Node{Kind: NodeEmpty}, nil
+	case syntax.OpCapture:
+		subs, err := convertSubs(true)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: NodeGroup, Subs: subs}, nil
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		// anchors in a sub repeated more than once would be in the middle
+		// of the pattern from the second time
+		if re.Op == syntax.OpStar || re.Op == syntax.OpPlus || re.Op == syntax.OpRepeat && (re.Max == -1 || re.Max > 1) {
+			atStart, atEnd = false, false
+		}
+		subs, err := convertSubs(true)
+		if err != nil {
+			return nil, err
+		}
+		n := &Node{Kind: NodeRepeat, Subs: subs}
+		switch re.Op {
+		case syntax.OpStar:
+			n.Min, n.Max = 0, -1
+		case syntax.OpPlus:
+			n.Min, n.Max = 1, -1
+		case syntax.OpQuest:
+			n.Min, n.Max = 0, 1
+		default:
+			n.Min, n.Max = re.Min, re.Max
+		}
+		return n, nil
+	case syntax.OpConcat, syntax.OpAlternate:
+		if len(re.Sub) == 0 {
+			return &Node{Kind: NodeEmpty}, nil
+		}
+		subs, err := convertSubs(re.Op == syntax.OpAlternate)
+		if err != nil {
+			return nil, err
+		}
+		if re.Op == syntax.OpConcat {
+			return &Node{Kind: NodeConcat, Subs: subs}, nil
+		}
+		return &Node{Kind: NodeAlternate, Subs: subs}, nil
+	}
+	return nil, fmt.Errorf("unsupported %v in %q", re.Op, re)
+}
+
+// foldLiteral returns a node matching the rune, or any rune equivalent to it
+// under simple case folding if fold is true.
+func foldLiteral(r rune, fold bool) *Node {
+	if !fold {
+		return &Node{Kind: NodeLiteral, Rune: r}
+	}
+	n := &Node{Kind: NodeCharClass, Ranges: [][2]rune{{r, r}}}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		n.Ranges = append(n.Ranges, [2]rune{f, f})
+	}
+	return n
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestCompileSyntax(t *testing.T) {
+	patterns := []string{
+		`^[a-z]+\d{2,4}$`,
+		`(?i)hello|wor+ld`,
+		`a{3}|b{2,}|c{0,2}x`,
+		`(foo)?(?:bar)*\.baz`,
+		`(?s)a.b`,
+		`[^\n]*`,
+		`x*?y+?`,
+		`\pL\p{Greek}`,
+	}
+	inputs := []string{
+		"", "ab12", "abc1234", "abc12345", "HeLLo", "world", "woooRld", "aaa",
+		"bb", "bbbb", "x", "ccx", "cccx", "foobar.baz", ".baz", "barbar.baz",
+		"a\nb", "a.b", "xxy", "yyy", "aπ", "αβ",
+	}
+	for _, p := range patterns {
+		tree, err := syntax.Parse(p, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := CompileSyntax(tree)
+		if err != nil {
+			t.Errorf("CompileSyntax(%q): %v", p, err)
+			continue
+		}
+		g := regexp.MustCompile(`^(?:` + p + `)$`)
+		for _, s := range inputs {
+			if r.Match(s) != g.MatchString(s) {
+				t.Errorf("CompileSyntax(%q).Match(%q) = %v", p, s, r.Match(s))
+			}
+		}
+	}
+
+	// bounded repeats are written by repeating
+	tree, _ := syntax.Parse(`(ab){2,4}c{2,}d{0}`, syntax.Perl)
+	n, err := convertSyntax(tree, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != "(ab)(ab)((ab)(ab)?)?cc+~(.|\\n)+" {
+		t.Errorf("String() = %q", s)
+	}
+
+	// anchors in repeats or in some alternatives only aren't at the edges
+	for _, p := range []string{`a^b`, `a$b`, `\bword\b`, `(?m)a$\nb`, `(^a)+`, `(a$)+`, `(^a){2}`, `^a|b`, `a|b$`, `(?m)^a`} {
+		tree, err := syntax.Parse(p, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CompileSyntax(tree); err == nil {
+			t.Errorf("CompileSyntax(%q) succeeded", p)
+		}
+	}
+}
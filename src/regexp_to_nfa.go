@@ -130,8 +130,40 @@ func (n *nfa) alternate(f *nfa) {
 	n.states = newStates
 }
 
+// isolate makes sure that the start state can only be reached from the end
+// state, and the end state can only lead to the start state, by adding new
+// start or end state if necessary. Otherwise, an empty transfer from the start
+// state to the end state would let inner states skip the rest of the NFA.
+func (n *nfa) isolate() {
+	start, end := n.startState(), n.endState()
+	for _, t := range n.toStart {
+		isFromEnd := false
+		for _, u := range end.transfers {
+			isFromEnd = isFromEnd || t == u
+		}
+		if !isFromEnd {
+			// new start -> start
+			newStart := &nfaState{[]*nfaTransfer{{start, true, nil, nil}}}
+			n.states = append([]*nfaState{newStart}, n.states...)
+			n.toStart = nil
+			break
+		}
+	}
+	for _, t := range end.transfers {
+		if t.target != n.startState() {
+			// end -> new end
+			newEnd := &nfaState{}
+			end.transfers = append(end.transfers, &nfaTransfer{newEnd, true, nil, nil})
+			n.states = append(n.states, newEnd)
+			n.toEnd = []*nfaTransfer{end.peek()}
+			break
+		}
+	}
+}
+
 // repeatZeroTimesAndMore repeats the NFA for zero times and more.
 func (n *nfa) repeatZeroTimesAndMore() {
+	n.isolate()
 	start, end := n.startState(), n.endState()
 	start.transfers = append(start.transfers, &nfaTransfer{end, true, nil, nil})
 	end.transfers = append(end.transfers, &nfaTransfer{start, true, nil, nil})
@@ -148,203 +180,154 @@ func (n *nfa) repeatOnceAndMore() {
 
 // repeatOnceAndLess repeats this NFA for once and less.
 func (n *nfa) repeatOnceAndLess() {
+	n.isolate()
 	start, end := n.startState(), n.endState()
 	start.transfers = append(start.transfers, &nfaTransfer{end, true, nil, nil})
 	n.toEnd = append(n.toEnd, start.peek())
 }
 
-// nfaHelper helps parse regular expression.
-type nfaHelper struct {
-	par, alt *nfa
-	stack    []*nfa
-}
-
-// peek returns the last NFA in the stack.
-func (h *nfaHelper) peek() *nfa {
-	if len(h.stack) == 0 {
-		return nil
-	}
-	return h.stack[len(h.stack)-1]
+// charNFA returns an NFA accepting a single character in the given ranges.
+func charNFA(lower, upper []rune) *nfa {
+	start, end := &nfaState{}, &nfaState{}
+	start.transfers = append(start.transfers, &nfaTransfer{end, false, lower, upper})
+	return &nfa{nil, []*nfaTransfer{start.peek()}, []*nfaState{start, end}}
 }
 
-// pop returns the last NFA in the stack and removes it from the stack.
-func (h *nfaHelper) pop() *nfa {
-	t := h.peek()
-	if t != nil {
-		h.stack = h.stack[:len(h.stack)-1]
-	}
-	return t
+// constructNFA receives regular expression and outputs NFA.
+func constructNFA(regexp string) *nfa {
+	return buildNFA(parse(regexp))
 }
 
-// char adds a new NFA into stack.
-func (h *nfaHelper) char(lower, upper []rune) {
-	start, end := &nfaState{}, &nfaState{}
-	start.transfers = append(start.transfers, &nfaTransfer{end, false, lower, upper})
-	h.stack = append(h.stack, &nfa{nil, []*nfaTransfer{start.peek()}, []*nfaState{start, end}})
+// compileNode converts syntax tree to DFA, with the literal required by the
+// syntax tree for searching.
+func (l *compileLimits) compileNode(node *Node) *dfa {
+	return l.compileNFA(l.buildNFA(node), node)
 }
 
-// repeat repeats the last NFA in the stack.
-func (h *nfaHelper) repeat(r rune) {
-	if r == '*' {
-		h.stack[len(h.stack)-1].repeatZeroTimesAndMore()
-	} else if r == '+' {
-		h.stack[len(h.stack)-1].repeatOnceAndMore()
-	} else if r == '?' {
-		h.stack[len(h.stack)-1].repeatOnceAndLess()
-	}
+// compileNFA converts the NFA built from the syntax tree to DFA, like
+// compileNode.
+func (l *compileLimits) compileNFA(n *nfa, node *Node) *dfa {
+	d := l.constructDFA(n)
+	d.required = requiredLiteral(node)
+	return d
 }
 
-// alter pushes an alternative mark into stack.
-func (h *nfaHelper) alter() {
-	h.stack = append(h.stack, h.alt)
+// buildNFA converts syntax tree to NFA.
+func buildNFA(node *Node) *nfa {
+	return noLimits.buildNFA(node)
 }
 
-// parenthesis pushes an parenthesis mark into stack.
-func (h *nfaHelper) parenthesis() {
-	h.stack = append(h.stack, h.par)
+// buildNFA is like the function buildNFA, but aborts if the NFA has too many
+// states.
+func (l *compileLimits) buildNFA(node *Node) *nfa {
+	l.check()
+	n := l.buildNodeNFA(node)
+	l.checkNFAStates(len(n.states))
+	return n
 }
 
-// group pops NFAs from stack until there is a parenthesis mark or alternative mark,
-// or the stack is empty, and connects these NFA into a single one.
-func (h *nfaHelper) group() {
-	// concatenate all NFAs after alternative mark (if exists)
-	var alters []*nfa
-	for {
-		last := len(h.stack) - 1
-		for last >= 0 && h.stack[last] != h.par && h.stack[last] != h.alt {
-			last--
+// buildNodeNFA converts a node to NFA, building its children by l.buildNFA.
+func (l *compileLimits) buildNodeNFA(node *Node) *nfa {
+	switch node.Kind {
+	case NodeLiteral:
+		return charNFA([]rune{node.Rune}, []rune{node.Rune})
+	case NodeAny:
+		return charNFA([]rune{0, '\n' + 1}, []rune{'\n' - 1, utf8.MaxRune})
+	case NodeCharClass:
+		return charNFA(node.area(node.Negated))
+	case NodeConcat:
+		n := l.buildNFA(node.Subs[0])
+		for _, s := range node.Subs[1:] {
+			n.concatenate(l.buildNFA(s))
 		}
-
-		if last == len(h.stack)-1 {
-			if last == -1 || h.stack[last] == h.par {
-				panic("empty group")
-			}
-			if h.stack[last] == h.alt {
-				panic("empty alternative")
-			}
+		return n
+	case NodeAlternate:
+		n := l.buildNFA(node.Subs[0])
+		for _, s := range node.Subs[1:] {
+			n.alternate(l.buildNFA(s))
 		}
-
-		n := h.stack[last+1]
-		for i := last + 2; i < len(h.stack); i++ {
-			n.concatenate(h.stack[i])
+		return n
+	case NodeIntersect:
+		n := l.buildNFA(node.Subs[0])
+		for _, s := range node.Subs[1:] {
+			n = l.intersectNFA(n, l.buildNFA(s))
 		}
-		alters = append(alters, n)
-		h.stack = h.stack[:last+1]
-
-		if t := h.pop(); t == nil || t == h.par {
-			break
+		return n
+	case NodeComplement:
+		if sub := node.Subs[0]; sub.Kind == NodeComplement {
+			// double complement cancels out
+			return l.buildNFA(sub.Subs[0])
 		}
+		return l.complementNFA(l.buildNFA(node.Subs[0]))
+	case NodeRepeat:
+		n := l.buildNFA(node.Subs[0])
+		switch {
+		case node.Min == 0 && node.Max == -1:
+			n.repeatZeroTimesAndMore()
+		case node.Min == 1 && node.Max == -1:
+			n.repeatOnceAndMore()
+		case node.Min == 0 && node.Max == 1:
+			n.repeatOnceAndLess()
+		default:
+			// every copy but the first shares a state with the previous one,
+			// so give up early instead of building copies in vain
+			copies := node.Min
+			if node.Max > copies {
+				copies = node.Max
+			}
+			l.checkNFAStates(copies*(len(n.states)-1) + 1)
+			return buildRepeatNFA(func() *nfa { return l.buildNFA(node.Subs[0]) }, node.Min, node.Max)
+		}
+		return n
+	case NodeGroup:
+		return l.buildNFA(node.Subs[0])
+	case NodeEmpty:
+		return emptyNFA()
 	}
-
-	// merge all alternatives after the last parenthesis mark
-	n := alters[len(alters)-1]
-	for i := len(alters) - 2; i >= 0; i-- {
-		n.alternate(alters[i])
-	}
-	h.stack = append(h.stack, n)
+	panic("unknown node")
 }
 
-// constructNFA receives regular expression and outputs NFA.
-func constructNFA(regexp string) *nfa {
-	var parCnt int
-	re := []rune(regexp)
-	p := nfaHelper{&nfa{}, &nfa{}, []*nfa{}}
-	for i := 0; i < len(re); i++ {
-		switch re[i] {
-		case '(':
-			parCnt++
-			p.parenthesis()
-		case ')':
-			if p.peek() == nil || p.peek() == p.par || p.peek() == p.alt {
-				panic("invalid parenthesis")
-			}
-			if parCnt == 0 {
-				panic("mismatched parentheses")
-			}
-			parCnt--
-			p.group()
-		case '*', '+', '?':
-			if p.peek() == nil || p.peek() == p.par || p.peek() == p.alt ||
-				i == 0 || re[i-1] == '*' || re[i-1] == '+' || re[i-1] == '?' {
-				panic("invalid repeat")
-			}
-			p.repeat(re[i])
-		case '|':
-			if p.peek() == nil || p.peek() == p.par || p.peek() == p.alt {
-				panic("invalid alternative")
-			}
-			p.alter()
-		case '.':
-			p.char([]rune{0, '\n' + 1}, []rune{'\n' - 1, utf8.MaxRune})
-		case '[':
-			i++
-			// negative flag
-			neg := false
-			if re[i] == '^' {
-				neg = true
-				i++
-			}
-
-			// read a character from regular expression
-			getChar := func() (result rune) {
-				if re[i] == '\\' {
-					i++
-					if re[i] == '^' || re[i] == '-' {
-						result = re[i]
-					} else {
-						result = decodeEscapable(re[i])
-					}
-				} else {
-					result = re[i]
-				}
-				i++
-				return result
-			}
-
-			// collect characters in the class
-			var area [][]rune
-			for {
-				if re[i] == ']' {
-					break
-				}
-				ch1 := getChar()
-				if re[i] == '-' {
-					i++
-					ch2 := getChar()
-					if ch1 > ch2 {
-						panic("illegal range")
-					}
-					area = append(area, []rune{ch1, ch2})
-				} else {
-					area = append(area, []rune{ch1, ch1})
-				}
+// buildRepeatNFA repeats the NFA returned by build for min to max (-1 if
+// unbounded) times, which is min copies of it followed by max-min nested
+// optional copies, like xx(xx?)?. build must return a new NFA on every call.
+func buildRepeatNFA(build func() *nfa, min, max int) *nfa {
+	n := emptyNFA()
+	for i := 0; i < min; i++ {
+		x := build()
+		if i == min-1 && max == -1 {
+			x.repeatOnceAndMore()
+		}
+		n.concatenate(x)
+	}
+	if min == 0 && max == -1 {
+		x := build()
+		x.repeatZeroTimesAndMore()
+		n.concatenate(x)
+	}
+	if max > min {
+		var opt *nfa
+		for i := min; i < max; i++ {
+			x := build()
+			if opt != nil {
+				x.concatenate(opt)
 			}
-
-			// construct new NFA
-			lower, upper := sortCharacterClass(neg, area)
-			p.char(lower, upper)
-		case '\\':
-			i++
-			r := decodeEscapable(re[i])
-			p.char([]rune{r}, []rune{r})
-		default:
-			p.char([]rune{re[i]}, []rune{re[i]})
+			x.repeatOnceAndLess()
+			opt = x
 		}
+		n.concatenate(opt)
 	}
+	return n
+}
 
-	if parCnt != 0 {
-		panic("mismatched parentheses")
-	}
-	p.group()
-	return p.pop()
+// emptyNFA returns an NFA accepting the empty string only.
+func emptyNFA() *nfa {
+	start, end := &nfaState{}, &nfaState{}
+	start.transfers = append(start.transfers, &nfaTransfer{end, true, nil, nil})
+	return &nfa{nil, []*nfaTransfer{start.peek()}, []*nfaState{start, end}}
 }
 
 // sortCharacterClass processes raw character class and outputs .
 func sortCharacterClass(neg bool, area [][]rune) ([]rune, []rune) {
-	if len(area) == 0 {
-		panic("empty character class")
-	}
-
 	// sort and remove overlap
 	sort.Slice(area, func(i, j int) bool {
 		return area[i][0] == area[j][0] && area[i][1] > area[j][1] || area[i][0] < area[j][0]
@@ -363,9 +346,10 @@ func sortCharacterClass(neg bool, area [][]rune) ([]rune, []rune) {
 	if neg {
 		var last rune
 		var invArea [][]rune
-		// note that area[0][0] cannot be 0 because it's unable to input
 		for i := 0; i < len(area); i++ {
-			invArea = append(invArea, []rune{last, area[i][0] - 1})
+			if last < area[i][0] {
+				invArea = append(invArea, []rune{last, area[i][0] - 1})
+			}
 			last = area[i][1] + 1
 		}
 		if last <= utf8.MaxRune {
@@ -383,16 +367,3 @@ func sortCharacterClass(neg bool, area [][]rune) ([]rune, []rune) {
 	}
 	return lower, upper
 }
-
-// decodeEscapable returns real value of escaped character.
-func decodeEscapable(r rune) rune {
-	escape := map[rune]rune{
-		'\\': '\\', '(': '(', ')': ')', '*': '*', '+': '+', '?': '?',
-		'|': '|', '.': '.', '[': '[', ']': ']',
-		't': '\t', 'r': '\r', 'n': '\n',
-	}
-	if v, ok := escape[r]; ok {
-		return v
-	}
-	panic("inescapable character")
-}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
+// REK is a compiled pattern. It's immutable once compiled, so a REK (and its
+// copies) is safe for concurrent use by multiple goroutines. Matching with
+// Match, MatchBytes, Contains, LongestPrefix and ShortestPrefix never
+// allocates memory.
 type REK struct {
 	d *dfa
 }
 
+// Match reports whether the whole string s is matched by re.
 func (re *REK) Match(s string) bool {
 	state := 0
 	for _, ch := range s {
@@ -20,14 +28,125 @@ func (re *REK) Match(s string) bool {
 	return re.d.states[state].isEnd
 }
 
+// MatchBytes reports whether the whole byte slice b is matched by re, where b
+// is decoded as UTF-8 in the same way as a string.
+func (re *REK) MatchBytes(b []byte) bool {
+	state := 0
+	for len(b) > 0 {
+		ch, size := utf8.DecodeRune(b)
+		b = b[size:]
+		state = re.d.nextState(state, ch)
+		if state == -1 {
+			return false
+		}
+	}
+	return re.d.states[state].isEnd
+}
+
+// LongestPrefix returns the end of the longest prefix of s matched by re in
+// bytes, or -1 if no prefix is matched.
+func (re *REK) LongestPrefix(s string) int {
+	return re.d.longestMatch(s, 0, nil)
+}
+
+// ShortestPrefix returns the end of the shortest prefix of s matched by re in
+// bytes, or -1 if no prefix is matched.
+func (re *REK) ShortestPrefix(s string) int {
+	state := 0
+	if re.d.states[state].isEnd {
+		return 0
+	}
+	for i, ch := range s {
+		if state = re.d.nextState(state, ch); state == -1 {
+			return -1
+		}
+		if re.d.states[state].isEnd {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			return i + size
+		}
+	}
+	return -1
+}
+
 func Compile(re string) REK {
-	n := constructNFA(re)
-	//fmt.Println(convertNFAToString(n))
-	d := constructDFA(n)
-	//fmt.Println(convertDFAToString(d))
+	d := noLimits.compileNode(parse(re))
+	if d.states[0].isEnd {
+		panic("empty string is accepted by this NFA")
+	}
 	return REK{d}
 }
 
+// Syntax is the dialect of patterns.
+type Syntax int
+
+const (
+	SyntaxRek Syntax = iota // rek syntax, as accepted by Compile
+	SyntaxERE               // POSIX extended regular expressions, as in grep -E
+	SyntaxBRE               // POSIX basic regular expressions, as in grep and sed
+)
+
+// CompileOptions are options of CompileWithOptions. Limits of 0 mean no limit,
+// and compiling fails with *ErrTooComplex if any limit is exceeded, so that
+// untrusted patterns can't exhaust resources.
+type CompileOptions struct {
+	Syntax Syntax
+
+	// MaxPatternLen is the maximum length of the pattern in bytes.
+	MaxPatternLen int
+	// MaxNFAStates is the maximum number of states of the NFA, including the
+	// ones built for intersections and complements.
+	MaxNFAStates int
+	// MaxDFAStates is the maximum number of states of every DFA constructed.
+	MaxDFAStates int
+	// MaxMemory is the maximum estimated memory in bytes used by every DFA
+	// construction.
+	MaxMemory int64
+}
+
+// CompileWithOptions is like Compile, but the pattern is written in the dialect
+// selected by opts, and errors are returned instead of panicking.
+func CompileWithOptions(re string, opts CompileOptions) (REK, error) {
+	return CompileContext(context.Background(), re, opts)
+}
+
+// CompileContext is like CompileWithOptions, but compiling is aborted with the
+// error of ctx once ctx is done. The automata used by searching are built when
+// compiling under the same limits, so searching with the REK never exceeds
+// them later.
+func CompileContext(ctx context.Context, re string, opts CompileOptions) (REK, error) {
+	r, _, err := compileWithNFA(ctx, re, opts)
+	return r, err
+}
+
+// compileWithNFA is like CompileContext, but also returns the NFA the DFA is
+// constructed from, for printing it.
+func compileWithNFA(ctx context.Context, re string, opts CompileOptions) (r REK, n *nfa, err error) {
+	defer catchCompileError(&err)
+
+	l := &compileLimits{ctx, opts}
+	l.checkLimit("MaxPatternLen", int64(len(re)), int64(opts.MaxPatternLen))
+	var node *Node
+	switch opts.Syntax {
+	case SyntaxRek:
+		node = parse(re)
+	case SyntaxERE:
+		node = parsePOSIX(re, false)
+	case SyntaxBRE:
+		node = parsePOSIX(re, true)
+	default:
+		return REK{}, nil, fmt.Errorf("unknown syntax %d", opts.Syntax)
+	}
+	n = l.buildNFA(node)
+	d := l.compileNFA(n, node)
+	if d.states[0].isEnd {
+		return REK{}, nil, errors.New("empty string is accepted by this NFA")
+	}
+	// the reverse DFA used by searching may be exponentially larger, so it's
+	// built under the same limits rather than on the first search
+	l.reverseDFA(d)
+	return REK{d}, n, nil
+}
+
 // convertNFAToString converts NFA to human-readable string.
 func convertNFAToString(n *nfa) string {
 	if n == nil {
@@ -80,3 +199,33 @@ func convertDFAToString(d *dfa) string {
 	}
 	return sb.String()
 }
+
+// convertDFAToDot converts DFA to a graph in the DOT language of Graphviz,
+// where transfers to the same state are merged into a single edge.
+func convertDFAToDot(d *dfa) string {
+	var sb strings.Builder
+	sb.WriteString("digraph dfa {\n  rankdir=LR;\n  start [shape=point];\n  start -> 0;\n")
+	for i, s := range d.states {
+		shape := "circle"
+		if s.isEnd {
+			shape = "doublecircle"
+		}
+		sb.WriteString(fmt.Sprintf("  %d [shape=%s];\n", i, shape))
+	}
+	for i, s := range d.states {
+		area := map[int][][]rune{}
+		var targets []int
+		for _, t := range s.transfers {
+			if _, ok := area[t.target]; !ok {
+				targets = append(targets, t.target)
+			}
+			area[t.target] = append(area[t.target], []rune{t.lower, t.upper})
+		}
+		for _, target := range targets {
+			label := classString(sortCharacterClass(false, area[target]))
+			sb.WriteString(fmt.Sprintf("  %d -> %d [label=%q];\n", i, target, label))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -30,6 +32,9 @@ func TestConstructNFA(t *testing.T) {
 		"[^a-zA-Z0-9]",
 		"[^.\\\\\\\\]",
 		"[\\^\\-\\]]",
+		"~a&[a-z]",
+		"a&",
+		"a~",
 	}
 	for _, re := range cases {
 		wrapper(re)
@@ -61,25 +66,254 @@ func TestConstructDFA(t *testing.T) {
 }
 
 func TestMatch(t *testing.T) {
-	input := []string{
-		"aaabbb123xyz",
-		"aaabbbabc",
-		"0xabc",
-		"bbbbbbabc",
+	RunSuite(t, "testdata/match.suite")
+}
+
+func TestPrefix(t *testing.T) {
+	cases := []struct {
+		re, in            string
+		longest, shortest int
+	}{
+		{"[0-9]+(\\.[0-9]+)?", "3.14+x", 4, 1},
+		{"[0-9]+(\\.[0-9]+)?", "3.x", 1, 1},
+		{"[0-9]+(\\.[0-9]+)?", "x3", -1, -1},
+		{"ab|abcd", "abcde", 4, 2},
+		{"ab|abcd", "abc", 2, 2},
+		{"ab|abcd", "a", -1, -1},
+		{"é+", "ééa", 4, 2},
+		{"--|-", "-\xff", 1, 1},
+	}
+	for _, c := range cases {
+		r := Compile(c.re)
+		if got := r.LongestPrefix(c.in); got != c.longest {
+			t.Errorf("%s: LongestPrefix(%q) = %d, want %d", c.re, c.in, got, c.longest)
+		}
+		if got := r.ShortestPrefix(c.in); got != c.shortest {
+			t.Errorf("%s: ShortestPrefix(%q) = %d, want %d", c.re, c.in, got, c.shortest)
+		}
+	}
+
+	// the empty prefix is matched by REKs accepting the empty string
+	a := Compile("a")
+	r := Complement(&a)
+	if r.LongestPrefix("ab") != 2 || r.ShortestPrefix("ab") != 0 {
+		t.Errorf("unexpected prefixes of %q", "ab")
+	}
+}
+
+func TestMatchOperator(t *testing.T) {
+	cases := []struct {
+		re string
+		in map[string]bool
+	}{
+		{"~(.*secret.*)&[a-z]+",
+			map[string]bool{"abc": true, "secret": false, "xsecrety": false, "a1": false}},
+		{"(a|b)*&~(.*aa.*)&.*b",
+			map[string]bool{"ab": true, "aab": false, "bab": true, "a": false}},
+		{"x~(a*)y",
+			map[string]bool{"xy": false, "xaay": false, "xby": true, "xaby": true}},
+		{"~~a|b&.",
+			map[string]bool{"a": true, "b": true, "aa": false}},
 	}
-	output := []bool{
-		false,
-		true,
-		true,
-		true,
+	for _, c := range cases {
+		r := Compile(c.re)
+		for s, want := range c.in {
+			if got := r.Match(s); got != want {
+				t.Errorf("%s: Match(%q) = %v, want %v", c.re, s, got, want)
+			}
+		}
 	}
+}
 
+func TestOperation(t *testing.T) {
+	ident := Compile("[a-zA-Z_][a-zA-Z0-9_]*")
+	keyword := Compile("if|else|for|func|return")
+	cases := []struct {
+		name string
+		r    REK
+		in   map[string]bool
+	}{
+		{"intersect", Intersect(&ident, &keyword),
+			map[string]bool{"if": true, "iff": false, "x": false, "": false}},
+		{"union", Union(&ident, &keyword),
+			map[string]bool{"if": true, "iff": true, "1x": false, "": false}},
+		{"difference", Difference(&ident, &keyword),
+			map[string]bool{"if": false, "iff": true, "_1": true, "func": false, "": false}},
+		{"complement", Complement(&keyword),
+			map[string]bool{"if": false, "iff": true, "": true, "1 2": true}},
+	}
+	for _, c := range cases {
+		for s, want := range c.in {
+			if got := c.r.Match(s); got != want {
+				t.Errorf("%s: Match(%q) = %v, want %v", c.name, s, got, want)
+			}
+		}
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	cases := []struct {
+		a, b    string
+		equal   bool
+		subset  bool
+		example string
+	}{
+		{"(a|b)*c", "(a*b*)*c", true, true, ""},
+		{"a+", "aa*", true, true, ""},
+		{"ab|ac", "a[bc]", true, true, ""},
+		{"a|ab", "ab*", false, true, "abb"},
+		{"[a-z]+", "[a-y]+", false, false, "z"},
+		{"x(\\n|.)", "x.", false, false, "x\n"},
+	}
+	for _, c := range cases {
+		a, b := Compile(c.a), Compile(c.b)
+		if equal, s := Equivalent(&a, &b); equal != c.equal || s != c.example {
+			t.Errorf("Equivalent(%q, %q) = %v, %q", c.a, c.b, equal, s)
+		} else if !equal && a.Match(s) == b.Match(s) {
+			t.Errorf("Equivalent(%q, %q): %q is not a counterexample", c.a, c.b, s)
+		}
+		if subset, s := Subset(&a, &b); subset != c.subset {
+			t.Errorf("Subset(%q, %q) = %v, %q", c.a, c.b, subset, s)
+		} else if !subset && (!a.Match(s) || b.Match(s)) {
+			t.Errorf("Subset(%q, %q): %q is not a counterexample", c.a, c.b, s)
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	r := Compile("(ab|c)[xy]?")
+	var got []string
+	r.Enumerate(3, func(s string) bool {
+		got = append(got, s)
+		return true
+	})
+	want := []string{"c", "ab", "cx", "cy", "abx", "aby"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Enumerate = %q, want %q", got, want)
+	}
+	for length, n := range []int64{0, 1, 3, 2, 0} {
+		if c := r.Count(length); c.Int64() != n {
+			t.Errorf("Count(%d) = %v, want %d", length, c, n)
+		}
+	}
+	// no string has a negative length
+	r.Enumerate(-1, func(s string) bool {
+		t.Errorf("Enumerate(-1) calls fn with %q", s)
+		return true
+	})
+	if c := r.Count(-1); c.Sign() != 0 {
+		t.Errorf("Count(-1) = %v, want 0", c)
+	}
+	if s, ok := r.ShortestMatch(); !ok || s != "c" {
+		t.Errorf("ShortestMatch = %q, %v", s, ok)
+	}
+	if r.IsEmpty() || !r.IsFinite() {
+		t.Errorf("IsEmpty = %v, IsFinite = %v", r.IsEmpty(), r.IsFinite())
+	}
+
+	r = Compile("[a-z]+&~[a-y]+")
+	if r.IsEmpty() || r.IsFinite() {
+		t.Errorf("IsEmpty = %v, IsFinite = %v", r.IsEmpty(), r.IsFinite())
+	}
+	if c := r.Count(2); c.Int64() != 51 {
+		t.Errorf("Count(2) = %v, want 51", c)
+	}
+	r = Compile("a&b")
+	if !r.IsEmpty() || !r.IsFinite() {
+		t.Errorf("IsEmpty = %v, IsFinite = %v", r.IsEmpty(), r.IsFinite())
+	}
+}
+
+func TestToPattern(t *testing.T) {
+	cases := []struct {
+		re, pattern string
+	}{
+		{"ab|ac", "a[bc]"},
+		{"return|result|retry", "re(sult|t(ry|urn))"},
+		{"(a|b|c|x)+", "[a-cx]+"},
+		{"[^a]|a", "(.|\\n)"},
+	}
+	for _, c := range cases {
+		r := Compile(c.re)
+		if p := r.ToPattern(); p != c.pattern {
+			t.Errorf("ToPattern(%q) = %q, want %q", c.re, p, c.pattern)
+		}
+	}
+
+	for _, re := range []string{
+		"(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)",
+		"\\.(a?b|(xy)+|yz*).\\t",
+		"[a-z]+&~(if|for)",
+		"(a|b)*a(a|b)",
+		"x((a+)?y)*",
+		"[\\^\\-\\]]+",
+	} {
+		r := Compile(re)
+		p := Compile(r.ToPattern())
+		if equal, s := Equivalent(&r, &p); !equal {
+			t.Errorf("ToPattern(%q) = %q, which differs on %q", re, r.ToPattern(), s)
+		}
+	}
+
+	if s := Simplify("abc|abd"); s != "ab[cd]" {
+		t.Errorf("Simplify = %q", s)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	r := Compile("(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)")
+	digits := Compile("[0-9]+")
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < 20; j++ {
+				// the first search builds the reverse DFA lazily
+				if m := r.FindAllStringIndex("aab1x 0xabc", -1); len(m) != 3 {
+					t.Errorf("FindAllStringIndex = %v", m)
+				}
+				if !r.Match("0xabc") || !r.MatchBytes([]byte("aaabbbabc")) || !r.Contains("-0xabc-") {
+					t.Error("unexpected results of matching")
+				}
+				if r.Explain("aaabbb123xyz").Offset != 6 {
+					t.Error("unexpected result of Explain")
+				}
+				u := Union(&r, &digits)
+				if !u.Match("123") || u.ToPattern() == "" {
+					t.Error("unexpected result of Union")
+				}
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
+
+func TestZeroAllocation(t *testing.T) {
 	r := Compile("(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)")
-	for i := 0; i < len(input); i++ {
-		if r.Match(input[i]) != output[i] {
-			t.Errorf(input[i])
+	b := []byte("aaabbbabc")
+	cases := []struct {
+		name string
+		fn   func()
+	}{
+		{"Match", func() { r.Match("aaabbbabc") }},
+		{"MatchBytes", func() { r.MatchBytes(b) }},
+		{"Contains", func() { r.Contains("--aaabbbabc--") }},
+		{"LongestPrefix", func() { r.LongestPrefix("aaabbbabc--") }},
+		{"ShortestPrefix", func() { r.ShortestPrefix("aaabbbabc--") }},
+	}
+	for _, c := range cases {
+		if n := testing.AllocsPerRun(100, c.fn); n != 0 {
+			t.Errorf("%s allocates %v time(s)", c.name, n)
 		}
 	}
+	// only the result is allocated
+	if n := testing.AllocsPerRun(100, func() { r.FindStringIndex("--aaabbbabc--") }); n != 1 {
+		t.Errorf("FindStringIndex allocates %v time(s)", n)
+	}
 }
 
 func BenchmarkCompileMatch(b *testing.B) {
@@ -94,6 +328,7 @@ func BenchmarkCompileMatch(b *testing.B) {
 
 func BenchmarkMatch(b *testing.B) {
 	r := Compile("(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)")
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		r.Match("aaabbb123xyz")
@@ -103,6 +338,29 @@ func BenchmarkMatch(b *testing.B) {
 	}
 }
 
+func BenchmarkMatchBytes(b *testing.B) {
+	r := Compile("(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)")
+	inputs := [][]byte{[]byte("aaabbb123xyz"), []byte("aaabbbabc"), []byte("0xabc"), []byte("bbbbbbabc")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, s := range inputs {
+			r.MatchBytes(s)
+		}
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	r := Compile("(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)")
+	s := strings.Repeat("-- 123 ", 100) + "aaabbbabc"
+	r.Contains(s)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Contains(s)
+	}
+}
+
 func BenchmarkRE2CompileMatch(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		r := regexp.MustCompile("(a*|b*)[0-9]?[a-zA-Z]+(x?y?z?|abc)")
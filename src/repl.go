@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const replHelp = `Enter a pattern first, and then strings to match against it.
+commands:
+  :pattern PATTERN   use another pattern
+  :test STRING       match a string, which may start with a colon
+  :min               print the minimized DFA and its pattern
+  :dot               print the DFA in the DOT language of Graphviz
+  :sample [N]        print N (default 5) random strings matched by the pattern
+  :equiv PATTERN     check whether the pattern is equivalent to another one
+  :help              print this message
+  :quit              exit
+`
+
+// replSession is the state of "rek repl".
+type replSession struct {
+	out io.Writer
+	rng *rand.Rand
+	re  *REK // nil if no pattern is entered yet
+}
+
+// runRepl implements "rek repl", which reads patterns, strings and commands
+// from the standard input until EOF or :quit.
+func runRepl(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	s := &replSession{out: os.Stdout, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	fmt.Fprint(s.out, replHelp)
+	s.run(os.Stdin, "> ")
+	return 0
+}
+
+// run reads lines from r and handles them, printing prompt before each line.
+func (s *replSession) run(r io.Reader, prompt string) {
+	scanner := bufio.NewScanner(r)
+	for fmt.Fprint(s.out, prompt); scanner.Scan(); fmt.Fprint(s.out, prompt) {
+		if !s.handle(scanner.Text()) {
+			return
+		}
+	}
+	fmt.Fprintln(s.out)
+}
+
+// handle handles a line, and returns false if the session should end.
+func (s *replSession) handle(line string) bool {
+	if !strings.HasPrefix(line, ":") {
+		if s.re == nil {
+			s.setPattern(line)
+		} else {
+			s.test(line)
+		}
+		return true
+	}
+
+	cmd, arg := line[1:], ""
+	if i := strings.IndexByte(cmd, ' '); i != -1 {
+		cmd, arg = cmd[:i], cmd[i+1:]
+	}
+	switch cmd {
+	case "quit", "q":
+		return false
+	case "help", "h":
+		fmt.Fprint(s.out, replHelp)
+		return true
+	case "pattern", "p":
+		s.setPattern(arg)
+		return true
+	}
+	if s.re == nil {
+		fmt.Fprintln(s.out, "enter a pattern first")
+		return true
+	}
+
+	switch cmd {
+	case "test", "t":
+		s.test(arg)
+	case "min":
+		d := minimizeDFA(s.re.d)
+		fmt.Fprint(s.out, convertDFAToString(d))
+		fmt.Fprintln(s.out, "pattern:", s.re.ToPattern())
+	case "dot":
+		fmt.Fprint(s.out, convertDFAToDot(s.re.d))
+	case "sample":
+		n := 5
+		if arg != "" {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil || n < 0 {
+				fmt.Fprintf(s.out, "invalid number %q\n", arg)
+				return true
+			}
+		}
+		for i := 0; i < n; i++ {
+			str, ok := s.re.Generate(s.rng, GenerateOptions{})
+			if !ok {
+				fmt.Fprintln(s.out, "no string is matched")
+				break
+			}
+			fmt.Fprintf(s.out, "%q\n", str)
+		}
+	case "equiv":
+		other, err := compilePattern(arg)
+		if err != nil {
+			fmt.Fprintln(s.out, err)
+			return true
+		}
+		if equal, str := Equivalent(s.re, &other); equal {
+			fmt.Fprintln(s.out, "equivalent")
+		} else if s.re.Match(str) {
+			fmt.Fprintf(s.out, "not equivalent: %q is matched by the current pattern only\n", str)
+		} else {
+			fmt.Fprintf(s.out, "not equivalent: %q is matched by the other pattern only\n", str)
+		}
+	default:
+		fmt.Fprintf(s.out, "unknown command %q, enter :help for help\n", cmd)
+	}
+	return true
+}
+
+// setPattern compiles a pattern and prints its syntax tree and automata.
+func (s *replSession) setPattern(pattern string) {
+	r, n, err := compilePatternNFA(pattern)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.re = &r
+	node, _ := Parse(pattern)
+	fmt.Fprintln(s.out, "parsed:", node)
+	fmt.Fprintf(s.out, "NFA: %d state(s), DFA: %d state(s), minimal DFA: %d state(s)\n",
+		len(n.states), len(r.d.states), len(minimizeDFA(r.d).states))
+	fmt.Fprint(s.out, convertDFAToString(r.d))
+}
+
+// test matches a string against the pattern and prints the result.
+func (s *replSession) test(str string) {
+	report := s.re.Explain(str)
+	if report.Matched {
+		fmt.Fprintln(s.out, "matched")
+		return
+	}
+	fmt.Fprintf(s.out, "not matched: %v\n", report)
+	if report.LongestPrefix == -1 {
+		fmt.Fprintln(s.out, "longest matched prefix: none")
+	} else {
+		fmt.Fprintf(s.out, "longest matched prefix: %q\n", str[:report.LongestPrefix])
+	}
+}
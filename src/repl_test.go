@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	input := `:min
+a(b|c)+
+abcb
+abd
+:test :x
+:sample 3
+:equiv a[bc]+
+:equiv a[bc]*
+:min
+:dot
+:what
+:pattern (
+:pattern ` + strings.Repeat("b", 200) + `
+:quit
+ignored
+`
+	// patterns are compiled with the limits of cliOptions
+	defer func(opts CompileOptions) { cliOptions = opts }(cliOptions)
+	cliOptions.MaxNFAStates = 100
+
+	var out bytes.Buffer
+	s := &replSession{out: &out, rng: rand.New(rand.NewSource(1))}
+	s.run(strings.NewReader(input), "")
+	want := []string{
+		"enter a pattern first\n",
+		"parsed: a(b|c)+\nNFA: ",
+		"DFA with 3 state(s)\n",
+		"matched\n",
+		"not matched: unexpected 'd' at offset 2, expected [bc] or end of input\nlongest matched prefix: \"ab\"\n",
+		"not matched: unexpected ':' at offset 0, expected a\nlongest matched prefix: none\n",
+		"equivalent\n",
+		`not equivalent: "a" is matched by the other pattern only`,
+		"pattern: a[bc]+\n",
+		"digraph dfa {",
+		"unknown command \"what\"",
+		"mismatched parentheses",
+		"MaxNFAStates of 100 exceeded",
+	}
+	got := out.String()
+	for _, w := range want {
+		i := strings.Index(got, w)
+		if i == -1 {
+			t.Fatalf("output doesn't contain %q in order:\n%s", w, out.String())
+		}
+		got = got[i+len(w):]
+	}
+	if strings.Count(out.String(), "\n\"a") != 3 {
+		t.Errorf("unexpected samples:\n%s", out.String())
+	}
+}
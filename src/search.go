@@ -0,0 +1,376 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Contains reports whether s contains any match of re.
+func (re *REK) Contains(s string) bool {
+	return re.d.leftmostStart(s) != -1
+}
+
+// FindStringIndex returns the leftmost-longest match of re in s as a pair of
+// byte offsets, or nil if there is no match. Unlike Match, a match can be any
+// substring of s. It takes time linear in the length of s.
+func (re *REK) FindStringIndex(s string) []int {
+	start := re.d.leftmostStart(s)
+	if start == -1 {
+		return nil
+	}
+	return []int{start, re.d.longestMatch(s, start, nil)}
+}
+
+// FindString returns the text of the leftmost-longest match of re in s, or
+// the empty string if there is no match.
+func (re *REK) FindString(s string) string {
+	if m := re.FindStringIndex(s); m != nil {
+		return s[m[0]:m[1]]
+	}
+	return ""
+}
+
+// FindAllStringIndex returns successive non-overlapping leftmost-longest
+// matches of re in s, or nil if there is no match. Like package regexp, it
+// returns at most n matches if n >= 0, and empty matches abutting a preceding
+// match are ignored. It takes time linear in the length of s.
+func (re *REK) FindAllStringIndex(s string, n int) [][]int {
+	if n < 0 {
+		n = len(s) + 1
+	}
+	starts := re.d.matchStarts(s)
+	memo := &longestMemo{dict: map[[2]int]int{}}
+	var result [][]int
+	prevEnd := -1
+	for pos := 0; pos <= len(s) && len(result) < n; {
+		for pos < len(s) && !starts[pos] {
+			pos++
+		}
+		if !starts[pos] {
+			break
+		}
+		end := re.d.longestMatch(s, pos, memo)
+		if end > pos || pos != prevEnd {
+			result = append(result, []int{pos, end})
+			prevEnd = end
+		}
+		if end > pos {
+			pos = end
+		} else if pos == len(s) {
+			break
+		} else {
+			_, size := utf8.DecodeRuneInString(s[pos:])
+			pos += size
+		}
+	}
+	return result
+}
+
+// FindAllString returns the texts of successive non-overlapping matches of re
+// in s, as FindAllStringIndex does.
+func (re *REK) FindAllString(s string, n int) []string {
+	var result []string
+	for _, m := range re.FindAllStringIndex(s, n) {
+		result = append(result, s[m[0]:m[1]])
+	}
+	return result
+}
+
+// ReplaceAllString returns a copy of src, replacing matches of re with repl.
+// In repl, $0 or ${0} is replaced by the text of the match, and $$ by a single
+// $. As rek has no captures, other variables like $1 and ${name} are replaced
+// by the empty string, which is what package regexp does for missing groups.
+func (re *REK) ReplaceAllString(src, repl string) string {
+	return re.replaceAll(src, func(dst []byte, m []int) []byte {
+		return expand(dst, repl, src, m)
+	})
+}
+
+// ReplaceAllLiteralString returns a copy of src, replacing matches of re with
+// repl, which is substituted directly without expansion.
+func (re *REK) ReplaceAllLiteralString(src, repl string) string {
+	return re.replaceAll(src, func(dst []byte, _ []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceAllStringFunc returns a copy of src, replacing matches of re with the
+// return value of repl applied to the matched text.
+func (re *REK) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	return re.replaceAll(src, func(dst []byte, m []int) []byte {
+		return append(dst, repl(src[m[0]:m[1]])...)
+	})
+}
+
+// replaceAll replaces matches of re in src with the output of repl.
+func (re *REK) replaceAll(src string, repl func(dst []byte, m []int) []byte) string {
+	matches := re.FindAllStringIndex(src, -1)
+	if matches == nil {
+		return src
+	}
+	var buf []byte
+	last := 0
+	for _, m := range matches {
+		buf = append(buf, src[last:m[0]]...)
+		buf = repl(buf, m)
+		last = m[1]
+	}
+	buf = append(buf, src[last:]...)
+	return string(buf)
+}
+
+// Split slices s into substrings separated by matches of re, and returns the
+// substrings between them, like Split of package regexp. If n > 0, at most n
+// substrings are returned, the last one being the unsplit remainder. If n == 0,
+// the result is nil, and if n < 0, all substrings are returned.
+func (re *REK) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	if len(s) == 0 {
+		return []string{""}
+	}
+	matches := re.FindAllStringIndex(s, n)
+	result := make([]string, 0, len(matches))
+	begin, end := 0, 0
+	for _, m := range matches {
+		if n > 0 && len(result) == n-1 {
+			break
+		}
+		end = m[0]
+		if m[1] != 0 {
+			result = append(result, s[begin:end])
+		}
+		begin = m[1]
+	}
+	if end != len(s) {
+		result = append(result, s[begin:])
+	}
+	return result
+}
+
+// expand appends template to dst, where variables are replaced by the match.
+// A variable is $name or ${name}, where name is a non-empty sequence of
+// letters, digits and underscores, and only the name 0 refers to the match.
+func expand(dst []byte, template, src string, match []int) []byte {
+	for len(template) > 0 {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		dst = append(dst, template[:i]...)
+		template = template[i+1:]
+		if len(template) > 0 && template[0] == '$' {
+			dst = append(dst, '$')
+			template = template[1:]
+			continue
+		}
+		name, rest, ok := extractName(template)
+		if !ok {
+			// malformed variables are kept as they are
+			dst = append(dst, '$')
+			continue
+		}
+		template = rest
+		if name == "0" {
+			dst = append(dst, src[match[0]:match[1]]...)
+		}
+	}
+	return append(dst, template...)
+}
+
+// extractName returns the name of a variable at the beginning of template,
+// which follows a $, and the rest of template.
+func extractName(template string) (name, rest string, ok bool) {
+	braced := len(template) > 0 && template[0] == '{'
+	if braced {
+		template = template[1:]
+	}
+	i := 0
+	for i < len(template) {
+		ch, size := utf8.DecodeRuneInString(template[i:])
+		if ch != '_' && !unicode.IsLetter(ch) && !unicode.IsDigit(ch) {
+			break
+		}
+		i += size
+	}
+	if i == 0 {
+		return "", "", false
+	}
+	name, rest = template[:i], template[i:]
+	if braced {
+		if len(rest) == 0 || rest[0] != '}' {
+			return "", "", false
+		}
+		rest = rest[1:]
+	}
+	return name, rest, true
+}
+
+// leftmostStart returns the byte offset where the leftmost match of d in s
+// starts, or -1 if there is no match, by scanning s backwards once down to the
+// first position where the literals of d allow a match to start.
+func (d *dfa) leftmostStart(s string) int {
+	// no match starts before the first candidate
+	first := -1
+	if d.mayMatch(s) {
+		first = d.nextCandidate(s, 0)
+	}
+	if first == -1 {
+		return -1
+	}
+	rev := d.reverseDFA()
+	start, state := -1, 0
+	if rev.states[0].isEnd {
+		start = len(s)
+	}
+	for i := len(s); i > first; {
+		ch, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+		if state = rev.nextState(state, ch); state == -1 {
+			break
+		}
+		if rev.states[state].isEnd {
+			start = i
+		}
+	}
+	if d.anchorStart && start > 0 {
+		return -1
+	}
+	return start
+}
+
+// matchStarts reports for every byte offset of s whether a match of d starts
+// there, by scanning s backwards once.
+func (d *dfa) matchStarts(s string) []bool {
+	starts := make([]bool, len(s)+1)
+	first := -1
+	if d.mayMatch(s) {
+		first = d.nextCandidate(s, 0)
+	}
+	if first == -1 {
+		return starts
+	}
+	rev := d.reverseDFA()
+	state := 0
+	starts[len(s)] = rev.states[0].isEnd
+	for i := len(s); i > first; {
+		ch, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+		if state = rev.nextState(state, ch); state == -1 {
+			break
+		}
+		starts[i] = rev.states[state].isEnd
+	}
+	if d.anchorStart {
+		for i := 1; i < len(starts); i++ {
+			starts[i] = false
+		}
+	}
+	return starts
+}
+
+// longestMemo remembers results of longestMatch, so that the text after a
+// match is not scanned again in the same state when looking for the next one.
+type longestMemo struct {
+	// dict maps a byte offset and a state to the end of the longest match
+	// from there, or -1
+	dict map[[2]int]int
+	// trail is the offsets and states passed by the current scan
+	trail [][2]int
+}
+
+// longestMatch returns the end of the longest match of d in s starting at the
+// i-th byte, or -1 if there is none.
+func (d *dfa) longestMatch(s string, i int, memo *longestMemo) int {
+	// best is the result remembered for the offset where the scan stops
+	state, end, best := 0, -1, -1
+	if memo != nil {
+		memo.trail = memo.trail[:0]
+	}
+	for p := i; ; {
+		if memo != nil {
+			if e, ok := memo.dict[[2]int{p, state}]; ok {
+				if e != -1 {
+					end, best = e, e
+				}
+				break
+			}
+			memo.trail = append(memo.trail, [2]int{p, state})
+		}
+		if d.states[state].isEnd {
+			end = p
+		}
+		if p == len(s) {
+			break
+		}
+		ch, size := utf8.DecodeRuneInString(s[p:])
+		if state = d.nextState(state, ch); state == -1 {
+			break
+		}
+		p += size
+	}
+	if memo == nil {
+		return end
+	}
+
+	// the longest match from each offset passed ends at the longest one from
+	// later offsets if any, or at the offset itself if it's an end state
+	for k := len(memo.trail) - 1; k >= 0; k-- {
+		t := memo.trail[k]
+		if best == -1 && d.states[t[1]].isEnd {
+			best = t[0]
+		}
+		// only text after the match can be scanned again
+		if t[0] >= end {
+			memo.dict[t] = best
+		}
+	}
+	return end
+}
+
+// reverseDFA returns a DFA matching reversed strings which end with a match of
+// d. Running it backwards over a string tells where matches of d start. For a
+// DFA built by CompileLiterals, it's an Aho–Corasick automaton with failure
+// links, rather than one derived from d by the subset construction.
+func (d *dfa) reverseDFA() *dfa {
+	return noLimits.reverseDFA(d)
+}
+
+// reverseDFA is like the method of dfa, but aborts if the reverse DFA has too
+// many states or uses too much memory, or if the context is done. Then d must
+// be discarded, since the reverse DFA is never built again.
+func (l *compileLimits) reverseDFA(d *dfa) *dfa {
+	d.reverseOnce.Do(func() {
+		if d.literal {
+			d.reverse = buildAhoCorasick(acyclicWords(d))
+			return
+		}
+		// the start state loops on any rune, unless matches are anchored at
+		// the end, and leads to end states of d, and the start state of d
+		// leads to the end state
+		n := &nfa{states: make([]*nfaState, len(d.states)+2)}
+		for i := range n.states {
+			n.states[i] = &nfaState{}
+		}
+		start, end := n.startState(), n.endState()
+		if !d.anchorEnd {
+			start.transfers = append(start.transfers, &nfaTransfer{start, false, []rune{0}, []rune{utf8.MaxRune}})
+			n.toStart = append(n.toStart, start.peek())
+		}
+		for i, s := range d.states {
+			if s.isEnd {
+				start.transfers = append(start.transfers, &nfaTransfer{n.states[i+1], true, nil, nil})
+			}
+			for _, t := range s.transfers {
+				from := n.states[t.target+1]
+				from.transfers = append(from.transfers, &nfaTransfer{n.states[i+1], false, []rune{t.lower}, []rune{t.upper}})
+			}
+		}
+		n.states[1].transfers = append(n.states[1].transfers, &nfaTransfer{end, true, nil, nil})
+		n.toEnd = append(n.toEnd, n.states[1].peek())
+		d.reverse = l.constructDFA(n)
+	})
+	return d.reverse
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	patterns := []string{`a+`, `a*`, `ab|a|bcd`, `x*y?`, `[0-9]+(?:\.[0-9]+)?`, `a|a*b`, `é+|ç`, `(?s).`,
+		`ERROR [0-9]+`, `.*ERROR`, `(?:foo|bar)baz`, `ab|cd|ef`, `\x{FFFD}b`,
+		`^abc`, `abc$`, `^(?:ab|a)$`, `a*$`, `^a|^b`, `\Aa+|\Ab+`, `(?:^a|^ab)c*$`}
+	inputs := []string{
+		"", "a", "baaac", "abcd bcd ab", "xyxxy yx", "pi=3.14, e=2.718.", "aaaab",
+		"aaaa", "ééçe", "a\xffb\xe2\x82", "x\ny", "xERROR 42 ERROR x\nERROR 7", "foobaz barbaz",
+		"abc", "xabc", "abcx", "abcabc", "baa", "aab",
+	}
+	for _, p := range patterns {
+		tree, err := syntax.Parse(p, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := CompileSyntax(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := regexp.MustCompile(p)
+		g.Longest()
+		for _, s := range inputs {
+			if got, want := r.FindStringIndex(s), g.FindStringIndex(s); !reflect.DeepEqual(got, want) {
+				t.Errorf("%q.FindStringIndex(%q) = %v, want %v", p, s, got, want)
+			}
+			for _, n := range []int{-1, 0, 1, 2} {
+				if got, want := r.FindAllStringIndex(s, n), g.FindAllStringIndex(s, n); !reflect.DeepEqual(got, want) {
+					t.Errorf("%q.FindAllStringIndex(%q, %d) = %v, want %v", p, s, n, got, want)
+				}
+				if got, want := r.Split(s, n), g.Split(s, n); !reflect.DeepEqual(got, want) {
+					t.Errorf("%q.Split(%q, %d) = %q, want %q", p, s, n, got, want)
+				}
+			}
+			for _, repl := range []string{"<$0>", "${0}$1$$", "$", "${0", "$x_y."} {
+				if got, want := r.ReplaceAllString(s, repl), g.ReplaceAllString(s, repl); got != want {
+					t.Errorf("%q.ReplaceAllString(%q, %q) = %q, want %q", p, s, repl, got, want)
+				}
+				if got, want := r.ReplaceAllLiteralString(s, repl), g.ReplaceAllLiteralString(s, repl); got != want {
+					t.Errorf("%q.ReplaceAllLiteralString(%q, %q) = %q, want %q", p, s, repl, got, want)
+				}
+			}
+			if got, want := r.ReplaceAllStringFunc(s, strings.ToUpper), g.ReplaceAllStringFunc(s, strings.ToUpper); got != want {
+				t.Errorf("%q.ReplaceAllStringFunc(%q) = %q, want %q", p, s, got, want)
+			}
+		}
+	}
+
+	// scanning for the longest match doesn't make FindAll quadratic
+	r := Compile("a|a*b")
+	s := strings.Repeat("a", 100000)
+	if m := r.FindAllStringIndex(s, -1); len(m) != len(s) {
+		t.Errorf("found %d matches, want %d", len(m), len(s))
+	}
+	if got := r.FindString("xxaab"); got != "aab" {
+		t.Errorf("FindString = %q", got)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A suite file lists patterns with strings they should accept or reject, one
+// item per line:
+//
+//	# a comment
+//	re [0-9]+(\.[0-9]+)?
+//	+ 3.14
+//	- 3.
+//	- ""
+//
+// A line starting with "re " begins a new pattern, and the following lines
+// starting with "+ " and "- " are strings accepted and rejected by it. Values
+// are the rest of lines, or Go string literals if they start with a quote, so
+// that the empty string or special characters can be written. Blank lines and
+// lines starting with # are ignored.
+
+// SuiteCase is a pattern in a suite file with its examples.
+type SuiteCase struct {
+	Pattern  string
+	Line     int // line number of the pattern
+	Examples []SuiteExample
+}
+
+// SuiteExample is a string that should be accepted or rejected by a pattern.
+type SuiteExample struct {
+	Input  string
+	Accept bool
+	Line   int
+}
+
+// ParseSuite parses a suite file.
+func ParseSuite(r io.Reader) ([]SuiteCase, error) {
+	var cases []SuiteCase
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var kind, value string
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			kind, value = line[:i], line[i+1:]
+		}
+		if strings.HasPrefix(value, "\"") {
+			v, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string literal %s", n, value)
+			}
+			value = v
+		}
+
+		switch kind {
+		case "re":
+			cases = append(cases, SuiteCase{Pattern: value, Line: n})
+		case "+", "-":
+			if len(cases) == 0 {
+				return nil, fmt.Errorf("line %d: example before any pattern", n)
+			}
+			c := &cases[len(cases)-1]
+			c.Examples = append(c.Examples, SuiteExample{value, kind == "+", n})
+		default:
+			return nil, fmt.Errorf("line %d: unknown line %q", n, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// CheckSuite compiles every pattern and checks its examples, and calls report
+// with the line number and a description of every failure.
+func CheckSuite(cases []SuiteCase, report func(line int, msg string)) {
+	for _, c := range cases {
+		r, err := compilePattern(c.Pattern)
+		if err != nil {
+			report(c.Line, err.Error())
+			continue
+		}
+		for _, e := range c.Examples {
+			switch matched := r.Match(e.Input); {
+			case e.Accept && !matched:
+				msg := fmt.Sprintf("%q is rejected by %q: %v", e.Input, c.Pattern, r.Explain(e.Input))
+				report(e.Line, msg)
+			case !e.Accept && matched:
+				report(e.Line, fmt.Sprintf("%q is accepted by %q", e.Input, c.Pattern))
+			}
+		}
+	}
+}
+
+// RunSuite checks a suite file in a test, reporting every failure as an error.
+func RunSuite(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cases, err := ParseSuite(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	CheckSuite(cases, func(line int, msg string) {
+		t.Errorf("%s:%d: %s", path, line, msg)
+	})
+}
+
+// runTest implements "rek test". It prints every failure in the suite files,
+// and exits with 0 if there is no failure, 1 if there are, and 2 on error.
+func runTest(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	status := 0
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rek:", err)
+			return 2
+		}
+		cases, err := ParseSuite(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rek: %s: %v\n", path, err)
+			return 2
+		}
+		var examples, failures int
+		CheckSuite(cases, func(line int, msg string) {
+			fmt.Printf("%s:%d: %s\n", path, line, msg)
+			failures++
+		})
+		for _, c := range cases {
+			examples += len(c.Examples)
+		}
+		fmt.Printf("%s: %d pattern(s), %d example(s), %d failure(s)\n", path, len(cases), examples, failures)
+		if failures > 0 {
+			status = 1
+		}
+	}
+	return status
+}
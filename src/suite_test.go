@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuite(t *testing.T) {
+	input := `# comment
+re a(b|c)+
+
++ ab
++ abd
+- "a\tc"
+- acb
+re a(
++ a
+`
+	cases, err := ParseSuite(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 || cases[0].Line != 2 || !reflect.DeepEqual(cases[0].Examples[2], SuiteExample{"a\tc", false, 6}) {
+		t.Fatalf("ParseSuite = %+v", cases)
+	}
+
+	var failures []string
+	CheckSuite(cases, func(line int, msg string) {
+		failures = append(failures, fmt.Sprintf("%d: %s", line, msg))
+	})
+	want := []string{
+		`5: "abd" is rejected by "a(b|c)+": unexpected 'd' at offset 2, expected [bc] or end of input`,
+		`7: "acb" is accepted by "a(b|c)+"`,
+		`8: invalid pattern "a(": mismatched parentheses at offset 1`,
+	}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("failures = %q", failures)
+	}
+
+	// patterns from files are compiled with the limits of the command line
+	if _, err := compilePattern(strings.Repeat("a", 1<<16)); err == nil || !strings.HasSuffix(err.Error(), "MaxNFAStates of 65536 exceeded") {
+		t.Errorf("compiling a pattern with too many states returns %v", err)
+	}
+
+	for _, s := range []string{"+ a\n", "re a\n- \"a\n", "re a\nx\n"} {
+		if _, err := ParseSuite(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseSuite(%q) succeeded", s)
+		}
+	}
+}
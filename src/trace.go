@@ -0,0 +1,48 @@
+package main
+
+// TraceStep is a step of matching a string, which consumes a rune.
+type TraceStep struct {
+	// Offset is the byte offset of Rune in the string.
+	Offset int
+	Rune   rune
+	// From and To are the DFA states before and after the step, where To is
+	// -1 if the rune leads to the dead state.
+	From, To int
+	// Lower and Upper are the range of the transfer taken, which are both -1
+	// if To is -1.
+	Lower, Upper rune
+	// FromNFA and ToNFA are the NFA states represented by From and To, which
+	// are nil if the REK is not compiled from a pattern, for example if it's
+	// returned by Intersect.
+	FromNFA, ToNFA []int
+}
+
+// Trace matches s against re like Match, and calls fn for every step. The
+// matching stops after a step to the dead state.
+func (re *REK) Trace(s string, fn func(TraceStep)) bool {
+	state := 0
+	for i, ch := range s {
+		step := TraceStep{Offset: i, Rune: ch, From: state, To: -1, Lower: -1, Upper: -1}
+		step.FromNFA = re.d.nfaStatesOf(state)
+		for _, t := range re.d.states[state].transfers {
+			if t.lower <= ch && ch <= t.upper {
+				step.To, step.Lower, step.Upper = t.target, t.lower, t.upper
+				step.ToNFA = re.d.nfaStatesOf(t.target)
+			}
+		}
+		fn(step)
+		if state = step.To; state == -1 {
+			return false
+		}
+	}
+	return re.d.states[state].isEnd
+}
+
+// nfaStatesOf returns the NFA states represented by a DFA state, or nil if
+// they are unknown.
+func (d *dfa) nfaStatesOf(state int) []int {
+	if d.nfaStates == nil {
+		return nil
+	}
+	return d.nfaStates[state]
+}
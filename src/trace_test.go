@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	r := Compile("a+b|ac")
+	var steps []TraceStep
+	matched := r.Trace("aab", func(step TraceStep) { steps = append(steps, step) })
+	want := []TraceStep{
+		{0, 'a', 0, 1, 'a', 'a', []int{0, 1}, []int{1, 2, 3}},
+		{1, 'a', 1, 2, 'a', 'a', []int{1, 2, 3}, []int{1, 2}},
+		{2, 'b', 2, 3, 'b', 'b', []int{1, 2}, []int{4}},
+	}
+	if !matched || !reflect.DeepEqual(steps, want) {
+		t.Errorf("Trace(%q) = %v, %+v", "aab", matched, steps)
+	}
+
+	// tracing stops at the dead state, and NFA states are unknown after set
+	// operations
+	b := Compile("b")
+	u := Union(&r, &b)
+	steps = nil
+	matched = u.Trace("aé", func(step TraceStep) { steps = append(steps, step) })
+	if matched || len(steps) != 2 || !reflect.DeepEqual(steps[1], TraceStep{1, 'é', steps[0].To, -1, -1, -1, nil, nil}) ||
+		steps[0].FromNFA != nil {
+		t.Errorf("Trace(%q) = %v, %+v", "aé", matched, steps)
+	}
+}